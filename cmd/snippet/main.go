@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"snippetbox.adpollak.net/internal/cli"
+)
+
+// The command-line client of snippetbox, for creating, reading, listing,
+// searching and deleting snippets from a terminal through the JSON API.
+// See the cli package for its commands.
+func main() {
+	c := &cli.CLI{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	err := c.Run(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, cli.ErrUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "snippet: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"snippetbox.adpollak.net/internal/models"
+	"snippetbox.adpollak.net/internal/validator"
+	"snippetbox.adpollak.net/ui"
+)
+
+// NOTE: the JSON API, under /api/v1. It has its own middleware chain without
+// sessions or CSRF protection, as its clients are scripts and editor plugins
+// rather than browsers; see authenticateToken() for how they authenticate.
+
+// The largest request body the API accepts, enough for a snippet with the most files.
+const maxAPIBody = 2 << 20
+
+// The most snippets a page of the API's listing can hold, chosen with ?limit=.
+const maxAPIPageSize = 100
+
+// A file of a snippet, as sent and received by the API.
+type apiFile struct {
+	Filename string `json:"filename"`
+	Language string `json:"language"`
+	Content  string `json:"content"`
+}
+
+// A snippet, as sent by the API.
+type apiSnippet struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"`
+	Title          string     `json:"title"`
+	Filename       string     `json:"filename,omitempty"`
+	Language       string     `json:"language"`
+	Content        string     `json:"content,omitempty"` // left out of listings
+	Files          []apiFile  `json:"files,omitempty"`   // the files after the first, left out of listings
+	Tags           []string   `json:"tags"`
+	Visibility     string     `json:"visibility"`
+	Author         string     `json:"author,omitempty"`
+	Created        time.Time  `json:"created"`
+	Expires        *time.Time `json:"expires"` // null if the snippet never expires
+	ViewsRemaining int        `json:"views_remaining,omitempty"`
+	Protected      bool       `json:"protected"`
+	Encrypted      bool       `json:"encrypted"` // if so, the content is ciphertext
+	ForkedFrom     string     `json:"forked_from,omitempty"`
+	Forks          int        `json:"forks"`
+	Stars          int        `json:"stars"`
+}
+
+// Return the API representation of a snippet, for the user userID (0 if
+// anonymous). The content and files are only included if withContent is true.
+func newAPISnippet(s *models.Snippet, userID int, withContent bool) apiSnippet {
+	as := apiSnippet{
+		ID:             s.ShortID,
+		URL:            "/snippet/view/" + s.ShortID,
+		Title:          s.Title,
+		Filename:       s.Filename,
+		Language:       s.Language,
+		Tags:           s.Tags,
+		Visibility:     s.Visibility,
+		Author:         s.Author,
+		Created:        s.Created,
+		ViewsRemaining: s.ViewsRemaining,
+		Protected:      s.Protected,
+		Encrypted:      s.Encrypted,
+		Forks:          s.Forks,
+		Stars:          s.Stars,
+	}
+
+	if as.Tags == nil {
+		as.Tags = []string{}
+	}
+	if !s.Expires.IsZero() {
+		as.Expires = &s.Expires
+	}
+	// Like the view page, only public originals are linked to.
+	if s.ForkedFrom != 0 && (s.ForkedFromVisibility == models.VisibilityPublic || s.OwnedBy(userID)) {
+		as.ForkedFrom = s.ForkedFromShortID
+	}
+
+	if withContent {
+		as.Content = s.Content
+		for _, f := range s.Files {
+			as.Files = append(as.Files, apiFile(*f))
+		}
+	}
+
+	return as
+}
+
+// Send a JSON error response, {"error": message}.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]string{"error": message})
+}
+
+// Like serverError, but the generic 500 Internal Server Error response is JSON.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	app.apiError(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
+
+// Send the errors of a failed validation as a 422 Unprocessable Entity response:
+// the error of each field by its name, and those not about a single field.
+func (app *application) apiValidationError(w http.ResponseWriter, v validator.Validator) {
+	fieldErrors := v.FieldErrors
+	if fieldErrors == nil {
+		fieldErrors = map[string]string{}
+	}
+	nonFieldErrors := v.NonFieldErrors
+	if nonFieldErrors == nil {
+		nonFieldErrors = []string{}
+	}
+
+	app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+		"error":            "The request failed validation",
+		"field_errors":     fieldErrors,
+		"non_field_errors": nonFieldErrors,
+	})
+}
+
+// Return the ID of the user a request to the API was authenticated as by
+// authenticateToken(), or 0 if it's anonymous.
+func apiUserID(r *http.Request) int {
+	id, _ := r.Context().Value(apiUserIDContextKey).(int)
+	return id
+}
+
+// Return the scope of the request to the API, one of models.ScopeRead or
+// models.ScopeWrite, or "" if it's anonymous.
+func apiScope(r *http.Request) string {
+	scope, _ := r.Context().Value(apiScopeContextKey).(string)
+	return scope
+}
+
+// Fetch the snippet named by the :id parameter of the request URL, which holds
+// its short ID. If it doesn't exist, or it's private and not owned by the user,
+// a 404 Not Found response is sent, ok is false and the caller should return
+// immediately. Unlike readSnippet, legacy integer IDs aren't accepted.
+func (app *application) apiFetchSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	snippet, err := app.snippets.Get(params.ByName("id"))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "Snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return nil, false
+	}
+
+	if !snippet.VisibleTo(apiUserID(r)) {
+		app.apiError(w, http.StatusNotFound, "Snippet not found")
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Like apiFetchSnippet, but the content of a snippet with a view limit or a
+// password can only be read by its owner; anyone else must use the view page,
+// and gets a 403 Forbidden response.
+func (app *application) apiReadSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiFetchSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !snippet.OwnedBy(apiUserID(r)) {
+		if snippet.ViewsRemaining > 0 {
+			app.apiError(w, http.StatusForbidden, "This snippet has a view limit, so can only be read on its view page")
+			return nil, false
+		}
+		if snippet.Protected {
+			app.apiError(w, http.StatusForbidden, "This snippet is password-protected, so can only be read on its view page")
+			return nil, false
+		}
+	}
+
+	return snippet, true
+}
+
+// Like apiFetchSnippet, but the snippet must be owned by the user, otherwise a
+// 403 Forbidden response is sent.
+func (app *application) apiOwnedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.apiFetchSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !snippet.OwnedBy(apiUserID(r)) {
+		app.apiError(w, http.StatusForbidden, "Only the owner of this snippet can change it")
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Serves the OpenAPI document describing the API, embedded in ui.Files.
+func (app *application) apiSpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := fs.ReadFile(ui.Files, "api/openapi.json")
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
+
+// Lists every unexpired public snippet, newest first, a page at a time. Like
+// the archive, pages are selected with the after and before cursors, given
+// as the next and prev URLs of each page; ?limit= sets the size of a page.
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	c := readCursor(r, archivePageSize)
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || !validator.Between(limit, 1, maxAPIPageSize) {
+			app.apiError(w, http.StatusBadRequest, fmt.Sprintf("The limit must be between 1 and %d", maxAPIPageSize))
+			return
+		}
+		c.Limit = limit
+	}
+
+	page, err := app.snippets.Page(c)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippets := []apiSnippet{}
+	for _, s := range page.Snippets {
+		snippets = append(snippets, newAPISnippet(s, apiUserID(r), false))
+	}
+
+	p := cursorPagination(r, page)
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"snippets": snippets,
+		"next":     p.NextURL, // empty on the last page
+		"prev":     p.PrevURL, // empty on the first page
+	})
+}
+
+// Lists the unexpired public snippets matching the ?q= query or tagged with
+// ?tag=, or both, like the search page. Results are ordered by relevance, so
+// they're paged by ?page= rather than by cursor, with next and prev URLs.
+func (app *application) apiSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+
+	if query == "" && tag == "" {
+		app.apiError(w, http.StatusBadRequest, "A query (q) or a tag is needed")
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	// Fetch one extra result to find out if there is a next page.
+	results, err := app.snippets.Search(query, tag, searchPageSize+1, (page-1)*searchPageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	p := &pagination{}
+	if len(results) > searchPageSize {
+		results = results[:searchPageSize]
+		p.NextURL = pageURL(r, "page", strconv.Itoa(page+1))
+	}
+	if page > 1 {
+		p.PrevURL = pageURL(r, "page", strconv.Itoa(page-1))
+	}
+
+	snippets := []apiSnippet{}
+	for _, s := range results {
+		snippets = append(snippets, newAPISnippet(s, apiUserID(r), false))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"snippets": snippets,
+		"next":     p.NextURL,
+		"prev":     p.PrevURL,
+	})
+}
+
+// Lists every unexpired snippet of the authenticated user, newest first,
+// including their unlisted and private ones.
+func (app *application) apiUserSnippets(w http.ResponseWriter, r *http.Request) {
+	results, err := app.snippets.ByUser(apiUserID(r))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	snippets := []apiSnippet{}
+	for _, s := range results {
+		snippets = append(snippets, newAPISnippet(s, apiUserID(r), false))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippets": snippets})
+}
+
+// Sends a single snippet, with its content and files.
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiReadSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, newAPISnippet(snippet, apiUserID(r), true))
+}
+
+// The JSON request creating a snippet. The fields are those of the create form,
+// checked the same way, except tags are a list. The visibility defaults to
+// public, and the snippet is kept for a year unless expires says otherwise.
+type apiSnippetCreateRequest struct {
+	Title        string    `json:"title"`
+	Filename     string    `json:"filename"`
+	Content      string    `json:"content"`
+	Language     string    `json:"language"`
+	Files        []apiFile `json:"files"`
+	Tags         []string  `json:"tags"`
+	Visibility   string    `json:"visibility"`
+	Expires      string    `json:"expires"`
+	ExpiresValue int       `json:"expires_value"`
+	ExpiresUnit  string    `json:"expires_unit"`
+	MaxViews     int       `json:"max_views"`
+	Password     string    `json:"password"`
+}
+
+// Creates a snippet owned by the authenticated user, and sends it back with
+// a 201 Created response.
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	var input apiSnippetCreateRequest
+
+	err := decodeJSON(w, r, &input, maxAPIBody)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "The request body must be a single JSON object of known fields: "+err.Error())
+		return
+	}
+
+	form := snippetCreateForm{
+		Title:        input.Title,
+		Filename:     input.Filename,
+		Content:      input.Content,
+		Language:     input.Language,
+		Tags:         strings.Join(input.Tags, " "),
+		Visibility:   input.Visibility,
+		Expires:      input.Expires,
+		ExpiresValue: input.ExpiresValue,
+		ExpiresUnit:  input.ExpiresUnit,
+		MaxViews:     input.MaxViews,
+		Password:     input.Password,
+	}
+	for _, f := range input.Files {
+		form.Files = append(form.Files, snippetFileForm{Filename: f.Filename, Content: f.Content, Language: f.Language})
+	}
+
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityPublic
+	}
+	if form.Expires == "" {
+		form.Expires = "365"
+	}
+
+	expires, tags := form.validate()
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	snippet := form.snippet(apiUserID(r), tags)
+
+	shortID, err := app.snippets.Insert(snippet, expires, form.Password)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// Rather than reading the snippet back, fill in what the database set.
+	snippet.ShortID = shortID
+	snippet.Created = time.Now().UTC()
+	if expires > 0 {
+		snippet.Expires = snippet.Created.Add(expires)
+	}
+	snippet.Protected = form.Password != ""
+
+	w.Header().Set("Location", "/api/v1/snippets/"+shortID)
+	app.writeJSON(w, http.StatusCreated, newAPISnippet(snippet, apiUserID(r), true))
+}
+
+// The JSON request updating a snippet. Only the fields given are changed; the
+// rest keep their current values. Like the edit form, only the first file of a
+// snippet can be changed, and its expiry, view limit and password can't be.
+type apiSnippetUpdateRequest struct {
+	Title      *string   `json:"title"`
+	Filename   *string   `json:"filename"`
+	Content    *string   `json:"content"`
+	Language   *string   `json:"language"`
+	Tags       *[]string `json:"tags"`
+	Visibility *string   `json:"visibility"`
+}
+
+// Updates a snippet owned by the authenticated user, and sends it back.
+func (app *application) apiSnippetUpdate(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !editable(snippet) {
+		app.apiError(w, http.StatusBadRequest, "Encrypted snippets can't be edited")
+		return
+	}
+
+	var input apiSnippetUpdateRequest
+
+	err := decodeJSON(w, r, &input, maxAPIBody)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, "The request body must be a single JSON object of known fields: "+err.Error())
+		return
+	}
+
+	form := snippetEditForm{
+		Title:      snippet.Title,
+		Filename:   snippet.Filename,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Tags:       strings.Join(snippet.Tags, " "),
+		Visibility: snippet.Visibility,
+	}
+	if input.Title != nil {
+		form.Title = *input.Title
+	}
+	if input.Filename != nil {
+		form.Filename = *input.Filename
+	}
+	if input.Content != nil {
+		form.Content = *input.Content
+	}
+	if input.Language != nil {
+		form.Language = *input.Language
+	}
+	if input.Tags != nil {
+		form.Tags = strings.Join(*input.Tags, " ")
+	}
+	if input.Visibility != nil {
+		form.Visibility = *input.Visibility
+	}
+
+	tags := form.validate(snippet)
+	if !form.Valid() {
+		app.apiValidationError(w, form.Validator)
+		return
+	}
+
+	form.apply(snippet, tags)
+
+	err = app.snippets.Update(snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "Snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, newAPISnippet(snippet, apiUserID(r), true))
+}
+
+// Deletes a snippet owned by the authenticated user, with a 204 No Content response.
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.apiOwnedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiError(w, http.StatusNotFound, "Snippet not found")
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"time"
+
+	"snippetbox.adpollak.net/internal/models"
+)
+
+// The formats the files of a snippet can be downloaded in, by the value of the
+// format query string parameter, and the extension of each.
+var archiveExtensions = map[string]string{
+	"zip":    ".zip",
+	"tar.gz": ".tar.gz",
+}
+
+// Write every file of a snippet to w as a zip archive, or as a gzipped tar archive
+// if format is "tar.gz". The files are named by archiveFilenames().
+func writeArchive(w io.Writer, format string, snippet *models.Snippet) error {
+	files := snippet.AllFiles()
+	names := archiveFilenames(snippet)
+
+	if format == "tar.gz" {
+		return writeTarGz(w, files, names, snippet.Created)
+	}
+	return writeZip(w, files, names, snippet.Created)
+}
+
+// Return the name of each file of a snippet in an archive. Files are named by
+// their filename, except a first file without one, which is named like when
+// it's downloaded on its own.
+func archiveFilenames(snippet *models.Snippet) []string {
+	names := []string{snippetFilename(snippet)}
+	for _, f := range snippet.Files {
+		names = append(names, f.Filename)
+	}
+	return names
+}
+
+func writeZip(w io.Writer, files []*models.File, names []string, modified time.Time) error {
+	zw := zip.NewWriter(w)
+
+	for i, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     names[i],
+			Method:   zip.Deflate,
+			Modified: modified,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.Content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeTarGz(w io.Writer, files []*models.File, names []string, modified time.Time) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for i, f := range files {
+		err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     names[i],
+			Mode:     0644,
+			Size:     int64(len(f.Content)),
+			ModTime:  modified,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(tw, f.Content); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"snippetbox.adpollak.net/internal/assert"
+	"snippetbox.adpollak.net/internal/cli"
+)
+
+// Return the command-line client, reading stdin and talking to the test server
+// with the config file at configPath.
+func newTestCLI(ts *testServer, configPath, stdin string) (*cli.CLI, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	c := &cli.CLI{
+		Stdin:      strings.NewReader(stdin),
+		Stdout:     stdout,
+		Stderr:     stderr,
+		ConfigPath: configPath,
+		HTTPClient: ts.Client(),
+	}
+	return c, stdout, stderr
+}
+
+// Write a config file for the test server and the given token, returning its path.
+func writeTestConfig(t *testing.T, ts *testServer, token string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+
+	cfg := &cli.Config{Server: ts.URL, Token: token}
+	if err := cfg.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCLILogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Valid token", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "snippetbox", "config.json")
+		c, stdout, _ := newTestCLI(ts, path, "sbx_read\n")
+
+		err := c.Run([]string{"login", ts.URL})
+
+		assert.NilError(t, err)
+		assert.Equal(t, stdout.String(), "Logged in to "+ts.URL+"\n")
+
+		cfg, err := cli.LoadConfig(path)
+		assert.NilError(t, err)
+		assert.Equal(t, cfg.Server, ts.URL)
+		assert.Equal(t, cfg.Token, "sbx_read")
+
+		// Only the user can read the token.
+		info, err := os.Stat(path)
+		assert.NilError(t, err)
+		assert.Equal(t, info.Mode().Perm(), os.FileMode(0600))
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.json")
+		c, _, _ := newTestCLI(ts, path, "sbx_revoked\n")
+
+		err := c.Run([]string{"login", ts.URL})
+
+		assert.StringContains(t, err.Error(), "The API token is invalid or has been revoked")
+
+		// Nothing is saved.
+		_, err = os.Stat(path)
+		assert.Equal(t, errors.Is(err, os.ErrNotExist), true)
+	})
+}
+
+func TestCLICreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	dir := t.TempDir()
+	mainGo := filepath.Join(dir, "main.go")
+	goMod := filepath.Join(dir, "go.mod")
+	os.WriteFile(mainGo, []byte("package main\n"), 0644)
+	os.WriteFile(goMod, []byte("module hello\n"), 0644)
+
+	tests := []struct {
+		name       string
+		token      string
+		args       []string
+		stdin      string
+		wantStdout string
+		wantErr    string
+	}{
+		{
+			name:       "From stdin",
+			token:      "sbx_write",
+			args:       []string{"create", "-title", "O snail", "-tags", "haiku,nature"},
+			stdin:      "Climb Mount Fuji",
+			wantStdout: ts.URL + "/snippet/view/newSnip2\n",
+		},
+		{
+			name:       "From files",
+			token:      "sbx_write",
+			args:       []string{"create", mainGo, goMod},
+			wantStdout: ts.URL + "/snippet/view/newSnip2\n",
+		},
+		{
+			name:    "Empty",
+			token:   "sbx_write",
+			args:    []string{"create"},
+			stdin:   "",
+			wantErr: "content: This field cannot be blank",
+		},
+		{
+			name:    "Missing file",
+			token:   "sbx_write",
+			args:    []string{"create", filepath.Join(dir, "missing.go")},
+			wantErr: "no such file or directory",
+		},
+		{
+			name:    "Read token",
+			token:   "sbx_read",
+			args:    []string{"create"},
+			stdin:   "Climb Mount Fuji",
+			wantErr: "This endpoint needs a token with the write scope",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI(ts, writeTestConfig(t, ts, tt.token), tt.stdin)
+
+			err := c.Run(tt.args)
+
+			if tt.wantErr != "" {
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, stdout.String(), tt.wantStdout)
+		})
+	}
+}
+
+func TestCLIGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout string
+		wantErr    string
+	}{
+		{
+			name:       "By ID",
+			args:       []string{"get", "oldPond1"},
+			wantStdout: "An old silent pond...",
+		},
+		{
+			name:       "By URL",
+			args:       []string{"get", ts.URL + "/snippet/view/oldPond1?lines=1#L1"},
+			wantStdout: "An old silent pond...",
+		},
+		{
+			name:       "Private snippet of the user",
+			args:       []string{"get", "smrRiver"},
+			wantStdout: "A summer river being crossed, how pleasing, with sandals in my hands!",
+		},
+		{
+			name:       "Further file",
+			args:       []string{"get", "-file", "go.mod", "twoFiles"},
+			wantStdout: "module example.com/hello\n",
+		},
+		{
+			name:    "Unknown file",
+			args:    []string{"get", "-file", "main_test.go", "twoFiles"},
+			wantErr: `snippet twoFiles has no file named "main_test.go"`,
+		},
+		{
+			name:    "Encrypted snippet",
+			args:    []string{"get", "encNote7"},
+			wantErr: "snippet encNote7 is encrypted",
+		},
+		{
+			name:    "Non-existent ID",
+			args:    []string{"get", "notThere"},
+			wantErr: "Snippet not found",
+		},
+		{
+			name:    "No ID",
+			args:    []string{"get"},
+			wantErr: cli.ErrUsage.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI(ts, writeTestConfig(t, ts, "sbx_read"), "")
+
+			err := c.Run(tt.args)
+
+			if tt.wantErr != "" {
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.StringContains(t, stdout.String(), tt.wantStdout)
+		})
+	}
+}
+
+func TestCLIList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Authenticated", func(t *testing.T) {
+		c, stdout, _ := newTestCLI(ts, writeTestConfig(t, ts, "sbx_read"), "")
+
+		err := c.Run([]string{"list"})
+
+		assert.NilError(t, err)
+		assert.StringContains(t, stdout.String(), "ID        VISIBILITY  EXPIRES           TITLE\n")
+		assert.StringContains(t, stdout.String(), "smrRiver  private")
+		assert.StringContains(t, stdout.String(), "oldPond1  public")
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		c, _, _ := newTestCLI(ts, writeTestConfig(t, ts, ""), "")
+
+		err := c.Run([]string{"list"})
+
+		assert.StringContains(t, err.Error(), "You must authenticate to use this endpoint")
+	})
+
+	t.Run("No server", func(t *testing.T) {
+		c, _, _ := newTestCLI(ts, filepath.Join(t.TempDir(), "config.json"), "")
+
+		err := c.Run([]string{"list"})
+
+		assert.StringContains(t, err.Error(), "no server is configured")
+	})
+}
+
+func TestCLISearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout string
+		wantStderr string
+		wantErr    error
+	}{
+		{
+			name:       "Match",
+			args:       []string{"search", "silent", "pond"},
+			wantStdout: "oldPond1",
+		},
+		{
+			name:       "Tag",
+			args:       []string{"search", "-tag", "haiku"},
+			wantStdout: "oldPond1",
+		},
+		{
+			name:       "No match",
+			args:       []string{"search", "frog"},
+			wantStderr: "No snippets found.",
+		},
+		{
+			name:    "No query",
+			args:    []string{"search"},
+			wantErr: cli.ErrUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Searching works anonymously, with the server given as a flag.
+			c, stdout, stderr := newTestCLI(ts, filepath.Join(t.TempDir(), "config.json"), "")
+
+			err := c.Run(append([]string{"-server", ts.URL}, tt.args...))
+
+			assert.Equal(t, err, tt.wantErr)
+			assert.StringContains(t, stdout.String(), tt.wantStdout)
+			assert.StringContains(t, stderr.String(), tt.wantStderr)
+		})
+	}
+}
+
+func TestCLIDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantStdout string
+		wantErr    string
+	}{
+		{
+			name:       "Owner",
+			args:       []string{"delete", "oldPond1"},
+			wantStdout: "Deleted oldPond1\n",
+		},
+		{
+			name:    "Not owner",
+			args:    []string{"delete", "wntrFrst"},
+			wantErr: "Only the owner of this snippet can change it",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout, _ := newTestCLI(ts, writeTestConfig(t, ts, "sbx_write"), "")
+
+			err := c.Run(tt.args)
+
+			if tt.wantErr != "" {
+				assert.StringContains(t, err.Error(), tt.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, stdout.String(), tt.wantStdout)
+		})
+	}
+}
@@ -5,3 +5,11 @@ type contextKey string
 // Unique key to use to store and retrieve authentication status from a
 // request context.
 const isAuthenticatedContextKey = contextKey("isAuthenticated")
+
+// Key to store and retrieve the ID of the user a request to the API was
+// authenticated as.
+const apiUserIDContextKey = contextKey("apiUserID")
+
+// Key to store and retrieve the scope of the API token a request was
+// authenticated with, one of models.ScopeRead or models.ScopeWrite.
+const apiScopeContextKey = contextKey("apiScope")
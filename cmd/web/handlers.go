@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
+	"snippetbox.adpollak.net/internal/diff"
 	"snippetbox.adpollak.net/internal/models"
 	"snippetbox.adpollak.net/internal/validator"
 
@@ -18,13 +24,118 @@ import (
 // (i.e., start w/ Capital letter). Struct fields must be exported
 // in order to be read by the html/template package when rendering a template.
 type snippetCreateForm struct {
-	Title   string `form:"title"`
-	Content string `form:"content"`
-	Expires int    `form:"expires"`
+	Title      string            `form:"title"`
+	Filename   string            `form:"filename"` // of the first file; needed once there are more files
+	Content    string            `form:"content"`
+	Language   string            `form:"language"` // empty to auto-detect
+	Files      []snippetFileForm `form:"files"`    // the files after the first, added by main.js
+	Tags       string            `form:"tags"`     // separated by commas or spaces
+	Visibility string            `form:"visibility"`
+	// A preset number of days, "never", or "custom" for ExpiresValue ExpiresUnit.
+	Expires      string `form:"expires"`
+	ExpiresValue int    `form:"expires_value"`
+	ExpiresUnit  string `form:"expires_unit"`
+	MaxViews     int    `form:"max_views"`   // 0 for no view limit
+	Password     string `form:"password"`    // empty for no password
+	ForkedFrom   string `form:"forked_from"` // short ID of the snippet being forked, if any
 	// FieldErrors map[string]string
 	validator.Validator `form:"-"` // composition
 }
 
+// Represent one of the files after the first on the create form, sent as
+// files[0].filename, files[0].content and so on.
+type snippetFileForm struct {
+	Filename string `form:"filename"`
+	Content  string `form:"content"`
+	Language string `form:"language"` // empty to detect from the filename or content
+}
+
+// Return the empty file the "file" template is rendered with, for main.js to
+// add to the create form when another file is added.
+func (f snippetCreateForm) NewFile() snippetFileForm {
+	return snippetFileForm{}
+}
+
+// Check the filenames and files of a snippet. Every file needs a distinct
+// filename once there is more than one; the errors of the files after the first
+// are added under files[0], files[1] and so on.
+func (f *snippetCreateForm) checkFiles() {
+	// Skip the files left entirely empty, such as ones added by mistake.
+	f.Files = slices.DeleteFunc(f.Files, func(file snippetFileForm) bool {
+		return strings.TrimSpace(file.Filename) == "" && strings.TrimSpace(file.Content) == ""
+	})
+
+	f.CheckField(len(f.Files) < models.MaxFiles, "files", fmt.Sprintf("A snippet cannot have more than %d files", models.MaxFiles))
+	f.CheckField(len(f.Files) == 0 || validator.NotBlank(f.Filename), "filename", "This field cannot be blank when there are several files")
+	f.CheckField(f.Filename == "" || validFilename(f.Filename), "filename", "This field must be a filename of at most 255 characters, without slashes")
+
+	seen := map[string]bool{f.Filename: true}
+	for i, file := range f.Files {
+		key := fmt.Sprintf("files[%d]", i)
+
+		f.CheckField(validator.NotBlank(file.Filename), key, "The filename cannot be blank")
+		f.CheckField(validFilename(file.Filename), key, "The filename must be at most 255 characters, without slashes")
+		f.CheckField(!seen[file.Filename], key, "Another file already has this filename")
+		f.CheckField(validator.NotBlank(file.Content), key, "The content cannot be blank")
+		f.CheckField(file.Language == "" || validator.PermittedValue(file.Language, languages...), key, "The language must be one of the listed languages")
+
+		seen[file.Filename] = true
+	}
+}
+
+// Check the fields of the create form, adding any errors to its Validator.
+// Returns how long the snippet is kept for, where 0 means it never expires, and
+// its tags. Shared by the create form and the API.
+func (f *snippetCreateForm) validate() (time.Duration, []string) {
+	f.CheckField(validator.NotBlank(f.Title), "title", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Title, 100), "title", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.NotBlank(f.Content), "content", "This field cannot be blank")
+	f.CheckField(f.Language == "" || validator.PermittedValue(f.Language, languages...), "language", "This field must be one of the listed languages")
+	f.checkFiles()
+	tags := parseTags(f.Tags)
+	checkTags(&f.Validator, tags)
+	f.CheckField(validator.PermittedValue(f.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must equal public, unlisted or private")
+	// A duration of 0 means the snippet never expires, otherwise it must be in range.
+	expires, ok := parseExpiry(f.Expires, f.ExpiresValue, f.ExpiresUnit)
+	f.CheckField(ok && (expires == 0 || validator.Between(expires, minExpiry, maxExpiry)), "expires", "This field must be between 10 minutes and 5 years, or never")
+	f.CheckField(validator.Between(f.MaxViews, 0, maxViews), "max_views", fmt.Sprintf("This field must be between 0 and %d", maxViews))
+	f.CheckField(f.Password == "" || validator.MinChars(f.Password, 8), "password", "This field must be at least 8 characters long")
+	// NOTE: bcrypt only hashes the first 72 bytes of a password.
+	f.CheckField(len(f.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+
+	return expires, tags
+}
+
+// Return the new snippet described by a valid create form, owned by userID.
+func (f *snippetCreateForm) snippet(userID int, tags []string) *models.Snippet {
+	// When no language was picked, detect it from the filename or content once
+	// here, so it doesn't have to be guessed every time the snippet is viewed.
+	if f.Language == "" {
+		f.Language = detectFileLanguage(f.Filename, f.Content)
+	}
+
+	var files []*models.File
+	for _, file := range f.Files {
+		if file.Language == "" {
+			file.Language = detectFileLanguage(file.Filename, file.Content)
+		}
+		files = append(files, &models.File{Filename: file.Filename, Language: file.Language, Content: file.Content})
+	}
+
+	return &models.Snippet{
+		Title:      f.Title,
+		Filename:   f.Filename,
+		Content:    f.Content,
+		Language:   f.Language,
+		Files:      files,
+		Tags:       tags,
+		Visibility: f.Visibility,
+		// The snippet deletes itself after this many views.
+		ViewsRemaining: f.MaxViews,
+		UserID:         userID,
+	}
+}
+
 // Handler
 // NOTE: This signature was changed to be defined as a method against the *application type.
 // This allows us to not depend on some specific type.
@@ -32,146 +143,1125 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 	// Before, we had to check the r.URL.Path != "/" for this handler.
 	// Since httprouter matches the "/" path EXACTLY, we can remove this.
 
-	snippets, err := app.snippets.Latest()
+	snippets, err := app.snippets.Latest()
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	mostStarred, err := app.stars.MostStarred(mostStarredSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	tags, err := app.tags.Cloud(tagCloudSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Call newTemplateData() helper to get a templateData struct containing the
+	// 'default' data (for now curr year) and add the snippets slice to it.
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.MostStarred = mostStarred
+	data.TagCloud = tagCloud(tags)
+
+	// Pass data to the render() helper
+	app.render(w, http.StatusOK, "home.tmpl", data)
+}
+
+// The number of tags in the tag cloud on the home page, and of snippets in
+// its most starred this week section.
+const (
+	tagCloudSize    = 30
+	mostStarredSize = 10
+)
+
+// The number of snippets listed on each page of the archive.
+const archivePageSize = 20
+
+// Handler for the archive, which lists every unexpired snippet, newest first,
+// a page at a time.
+func (app *application) snippetArchive(w http.ResponseWriter, r *http.Request) {
+	page, err := app.snippets.Page(readCursor(r, archivePageSize))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = page.Snippets
+	data.Pagination = cursorPagination(r, page)
+
+	app.render(w, http.StatusOK, "archive.tmpl", data)
+}
+
+// Lists the unexpired public snippets with a tag, newest first, a page at a time.
+func (app *application) tagView(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	tag := params.ByName("name")
+
+	// No snippet can have a tag which isn't valid.
+	if !validTag(tag) {
+		app.notFound(w)
+		return
+	}
+
+	page, err := app.snippets.ByTag(tag, readCursor(r, archivePageSize))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = page.Snippets
+	data.Pagination = cursorPagination(r, page)
+
+	app.render(w, http.StatusOK, "tag.tmpl", data)
+}
+
+// Handler
+func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
+	// Retrieve the snippet named by the id parameter. readSnippet has already
+	// sent a 404 Not Found response if there isn't one, or if it's private and
+	// the user doesn't own it.
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// A password-protected snippet must be unlocked before anything else.
+	if !app.isUnlocked(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+
+		app.render(w, http.StatusOK, "unlock.tmpl", data)
+		return
+	}
+
+	// Viewing a snippet with a view limit uses up one of its views, so readers
+	// are asked to confirm before its content is revealed. Owners can always see
+	// their own snippets without using up views.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.ViewsRemaining > 0 && !snippet.OwnedBy(userID) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+
+		app.render(w, http.StatusOK, "reveal.tmpl", data)
+		return
+	}
+
+	app.renderSnippet(w, r, snippet, false)
+}
+
+// Reveals the content of a snippet with a view limit once the reader has
+// confirmed they want to see it, using up one of its views.
+func (app *application) snippetRevealPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !app.isUnlocked(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.OwnedBy(userID) {
+		app.renderSnippet(w, r, snippet, false)
+		return
+	}
+
+	// Counting the view is left to Reveal(), which makes sure two readers can't
+	// both use up the last view. If someone else just did, the snippet is gone.
+	snippet, err := app.snippets.Reveal(snippet.ShortID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.renderSnippet(w, r, snippet, true)
+}
+
+// Represent the form data and validation errors for unlocking a
+// password-protected snippet.
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// The number of failed attempts to unlock a snippet each client is allowed
+// within unlockWindow, and the number all clients together are allowed, so the
+// password can't be guessed from many addresses either.
+const (
+	maxUnlockAttempts        = 5
+	maxSnippetUnlockAttempts = 50
+	unlockWindow             = 15 * time.Minute
+)
+
+// Checks the password given for a password-protected snippet, and if it's
+// right, unlocks the snippet for the rest of the session.
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if app.isUnlocked(r, snippet) {
+		http.Redirect(w, r, "/snippet/view/"+snippet.ShortID, http.StatusSeeOther)
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+	if !form.Valid() {
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		return
+	}
+
+	// Attempts are limited for each snippet and client, and for each snippet
+	// across all clients, so the password can't be guessed by brute force.
+	// NOTE: the snippet-wide limit is only checked once the client's allows the
+	// attempt, so a client already refused doesn't use up the attempts of others.
+	clientKey := fmt.Sprintf("%d %s", snippet.ID, clientIP(r))
+	snippetKey := strconv.Itoa(snippet.ID)
+	if !app.unlockLimiter.Allow(clientKey) || !app.snippetUnlockLimiter.Allow(snippetKey) {
+		form.AddNonFieldError("Too many failed attempts. Please try again later.")
+		data.Form = form
+		app.render(w, http.StatusTooManyRequests, "unlock.tmpl", data)
+		return
+	}
+
+	err = app.snippets.Unlock(snippet.ID, form.Password)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			form.AddNonFieldError("Password is incorrect")
+			data.Form = form
+			app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl", data)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	// The client's failed attempts are forgotten, but only this attempt is
+	// taken back from the snippet's, so one client knowing the password doesn't
+	// give the others more attempts.
+	app.unlockLimiter.Reset(clientKey)
+	app.snippetUnlockLimiter.Refund(snippetKey)
+	app.sessionManager.Put(r.Context(), unlockedKey(snippet), true)
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.ShortID, http.StatusSeeOther)
+}
+
+// Render the view page showing the content of a snippet. revealed is whether
+// showing it used up one of the snippet's views.
+func (app *application) renderSnippet(w http.ResponseWriter, r *http.Request, snippet *models.Snippet, revealed bool) {
+	data, err := app.snippetData(r, snippet, revealed)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Use our new render helper.
+	app.render(w, http.StatusOK, "view.tmpl", data)
+}
+
+// Return the template data for the view page of a snippet: its highlighted
+// code, whether the user has starred it, and its comments.
+func (app *application) snippetData(r *http.Request, snippet *models.Snippet, revealed bool) (*templateData, error) {
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = commentForm{}
+
+	// Highlight the code one line at a time so each line can be numbered and
+	// anchored. A range of lines, like ?lines=12-20, is highlighted on the server.
+	// Encrypted snippets are decrypted, and so shown, by the browser instead.
+	if !snippet.Encrypted {
+		code, err := highlightLines(snippet.Content, snippet.Language, parseLineRange(r.URL.Query().Get("lines")))
+		if err != nil {
+			return nil, err
+		}
+		data.Code = code
+
+		for _, f := range snippet.Files {
+			code, err := highlightLines(f.Content, f.Language, lineRange{})
+			if err != nil {
+				return nil, err
+			}
+			data.Files = append(data.Files, fileCode{File: f, Code: code})
+		}
+	}
+
+	// Only the owner of the snippet is shown the edit and delete controls.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	data.IsOwner = snippet.OwnedBy(userID)
+	data.Revealed = revealed
+
+	// Logged in users can star the snippet, or unstar it if they already have.
+	if userID != 0 {
+		starred, err := app.stars.Starred(userID, snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		data.Starred = starred
+	}
+
+	// Comments are only shown to readers who can see the content without using
+	// up a view, as they may quote it.
+	if !revealed && app.canReadContent(r, snippet) {
+		comments, err := app.comments.ForSnippet(snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		// Threads on a line of the first file are shown under that line; the
+		// rest, including any on lines since removed, below the snippet.
+		data.Commentable = true
+		data.Comments = []*commentNode{}
+		for _, thread := range threadComments(comments, userID, data.CSRFToken) {
+			if thread.Line > 0 && thread.Line <= len(data.Code) {
+				data.Code[thread.Line-1].Comments = append(data.Code[thread.Line-1].Comments, thread)
+			} else {
+				data.Comments = append(data.Comments, thread)
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// Returns only the content of a snippet as plain text, for use with tools
+// such as curl. Like snippetView, expired snippets are not found.
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	app.serveSnippetContent(w, r, snippet)
+}
+
+// Sends the content of a snippet as a file attachment, named after the
+// snippet's title or language.
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": snippetFilename(snippet)})
+	w.Header().Set("Content-Disposition", disposition)
+
+	app.serveSnippetContent(w, r, snippet)
+}
+
+// Sends every file of a snippet in a single archive, a zip archive by default,
+// or a gzipped tar archive for ?format=tar.gz.
+func (app *application) snippetDownloadArchive(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	ext, ok := archiveExtensions[format]
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	// Build the archive in memory first, so an error can still be reported
+	// with a 500 response rather than a truncated archive.
+	var buf bytes.Buffer
+	err := writeArchive(&buf, format, snippet)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	contentType := "application/zip"
+	if format == "tar.gz" {
+		contentType = "application/gzip"
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": titleFilename(snippet) + ext})
+	w.Header().Set("Content-Disposition", disposition)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet))
+
+	buf.WriteTo(w)
+}
+
+// The number of search results shown on each page.
+const searchPageSize = 10
+
+// Handler for the search page, which lists the unexpired snippets matching
+// the q query string parameter, within those with the tag parameter if it's given.
+// The page parameter selects later pages of results.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	// Optionally, only snippets with this tag are searched.
+	tag := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("tag")))
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+	data.Tag = tag
+	data.TitlesOnly = !app.snippets.SearchesContent()
+
+	// Without a query, every snippet with the tag is listed, newest first.
+	if query != "" || tag != "" {
+		// Fetch one extra result to find out if there is a next page.
+		snippets, err := app.snippets.Search(query, tag, searchPageSize+1, (page-1)*searchPageSize)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		// Search results are ordered by relevance rather than ID, so they are
+		// paged by offset instead of by cursor.
+		data.Pagination = &pagination{}
+		if len(snippets) > searchPageSize {
+			snippets = snippets[:searchPageSize]
+			data.Pagination.NextURL = pageURL(r, "page", strconv.Itoa(page+1))
+		}
+		if page > 1 {
+			data.Pagination.PrevURL = pageURL(r, "page", strconv.Itoa(page-1))
+		}
+		data.Snippets = snippets
+	}
+
+	app.render(w, http.StatusOK, "search.tmpl", data)
+}
+
+// Lists every stored revision of a snippet.
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.revisions.All(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, http.StatusOK, "history.tmpl", data)
+}
+
+// Displays a single past revision of a snippet.
+func (app *application) snippetRevision(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+
+	version, err := strconv.Atoi(params.ByName("version"))
+	if err != nil || version < 1 {
+		app.notFound(w)
+		return
+	}
+
+	revision, err := app.revisions.Get(snippet.ID, version)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revision = revision
+
+	app.render(w, http.StatusOK, "revision.tmpl", data)
+}
+
+// Displays a line-by-line unified diff between two revisions of a snippet,
+// given as the from and to query string parameters. By default the latest
+// revision is compared to the one before it.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.revisions.All(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	// Revisions are ordered most recent first.
+	to := revisions[0].Version
+	from := to - 1
+
+	query := r.URL.Query()
+	if v := query.Get("to"); v != "" {
+		to, err = strconv.Atoi(v)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+		from = to - 1
+	}
+	if v := query.Get("from"); v != "" {
+		from, err = strconv.Atoi(v)
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Version 0 stands for the empty snippet before the first revision.
+	var fromRevision, toRevision *models.Revision
+	for _, rev := range revisions {
+		switch rev.Version {
+		case from:
+			fromRevision = rev
+		case to:
+			toRevision = rev
+		}
+	}
+	if toRevision == nil || (fromRevision == nil && from != 0) {
+		app.notFound(w)
+		return
+	}
+	if fromRevision == nil {
+		fromRevision = &models.Revision{SnippetID: snippet.ID}
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+	data.DiffFrom = fromRevision
+	data.DiffTo = toRevision
+	data.Diff = diff.Unified(fromRevision.Content, toRevision.Content, 3)
+
+	app.render(w, http.StatusOK, "diff.tmpl", data)
+}
+
+// Render the html form from the GET method.
+func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
+	// w.Write([]byte("Display the form for creating a new snippet here..."))
+	data := app.newTemplateData(r)
+
+	// NOTE: init a new createSnippetForm instance and pass it to the template.
+	// Otherwise, upon visiting /snippet/create Go would try to eval some tmpl tag
+	// such as .Form.FieldErrors.title which would be nil
+	data.Form = snippetCreateForm{
+		Visibility:   models.VisibilityPublic, // default values
+		Expires:      "365",
+		ExpiresValue: 1,
+		ExpiresUnit:  "hours",
+	}
+
+	app.render(w, http.StatusOK, "create.tmpl", data)
+}
+
+// Processes and uses form data of snippet. Upon completion
+// redirects user to the view page to view their snippet.
+func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
+	/*
+		// Step 1: parse request body
+		err := r.ParseForm()
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+	*/
+	var form snippetCreateForm // zero-valued instance of snippetCreateForm struct.
+
+	// Step 2: retrieve PostForm() data. Expires is a integer
+	// so convert with strconv.Atoi() and throw an error is fail
+	// conversion.
+	/*
+		expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+		if err != nil {
+			app.clientError(w, http.StatusBadRequest)
+			return
+		}
+
+		form := snippetCreateForm{
+			Title:   r.PostForm.Get("title"),
+			Content: r.PostForm.Get("content"),
+			Expires: expires,
+		}
+	*/
+
+	// Use our helper to handle nil panic.
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Call validate() to execute our validation checks.
+	expires, tags := form.validate()
+
+	// The snippet is owned by whoever is currently logged in. This route sits
+	// behind requireAuthentication, so the authenticatedUserID is always set.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	// A fork links back to the snippet it was copied from, as long as the user
+	// can still read it. If they can't, it's published as a snippet of its own
+	// once they submit the form again.
+	var forkedFrom int
+	if form.ForkedFrom != "" {
+		source, err := app.snippets.Get(form.ForkedFrom)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		if err != nil || !source.VisibleTo(userID) || source.Encrypted || !app.canReadContent(r, source) {
+			form.ForkedFrom = ""
+			form.AddNonFieldError("The snippet you forked is no longer available, so this one won't link to it")
+		} else {
+			forkedFrom = source.ID
+		}
+	}
+
+	// Use Valid() to see if any checks failed.
+	// If so, re-render passing in the form as before.
+	if !form.Valid() {
+		// re-render
+		data := app.newTemplateData(r)
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		return
+	}
+
+	snippet := form.snippet(userID, tags)
+	snippet.ForkedFrom = forkedFrom
+
+	// insert the snippet and its expiration into db
+	shortID, err := app.snippets.Insert(snippet, expires, form.Password)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// NOTE: Use Put() to add a string value and corresponding flash key to session data.
+	app.sessionManager.Put(r.Context(), "flash", "Snippet created successfully!")
+
+	// w.Write([]byte("Create a new snippet...\"))
+	// Redirect the user to the relevant page for the snippet.
+	// Update redirect path to the new clean URL format.
+	http.Redirect(w, r, "/snippet/view/"+shortID, http.StatusSeeOther)
+}
+
+// Render the create form prefilled with a copy of a snippet, to be changed and
+// published as a new snippet owned by the user, which links back to the original.
+// Any snippet the user can read can be forked.
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	source, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
+	}
+
+	// The server can't read encrypted snippets, so can't copy them either.
+	if source.Encrypted {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var files []snippetFileForm
+	for _, f := range source.Files {
+		files = append(files, snippetFileForm{Filename: f.Filename, Content: f.Content, Language: f.Language})
+	}
+
+	// The settings of the new snippet start from the defaults, rather than
+	// copying the view limit or visibility of the original.
+	data := app.newTemplateData(r)
+	data.Form = snippetCreateForm{
+		Title:        source.Title,
+		Filename:     source.Filename,
+		Content:      source.Content,
+		Language:     source.Language,
+		Files:        files,
+		Tags:         strings.Join(source.Tags, " "),
+		Visibility:   models.VisibilityPublic,
+		Expires:      "365",
+		ExpiresValue: 1,
+		ExpiresUnit:  "hours",
+		ForkedFrom:   source.ShortID,
+	}
+
+	app.render(w, http.StatusOK, "create.tmpl", data)
+}
+
+// Represent the JSON request creating an encrypted snippet, and its validation
+// errors. The content was encrypted in the browser; the other fields are sent
+// in the clear, as the same settings as those of snippetCreateForm.
+type snippetEncryptedForm struct {
+	Title               string `json:"title"`
+	Content             string `json:"content"` // base64url of the IV followed by the AES-GCM ciphertext
+	Language            string `json:"language"`
+	Visibility          string `json:"visibility"`
+	Expires             string `json:"expires"`
+	ExpiresValue        int    `json:"expires_value"`
+	ExpiresUnit         string `json:"expires_unit"`
+	MaxViews            int    `json:"max_views"`
+	validator.Validator `json:"-"`
+}
+
+// The largest request body accepted when creating an encrypted snippet; the
+// content column holds at most 65,535 bytes.
+const maxEncryptedBody = 128 * 1024
+
+// Render the form for creating an encrypted snippet. Unlike the create form,
+// it's submitted by ui/static/js/encrypted.js, which encrypts the content first.
+func (app *application) snippetCreateEncrypted(w http.ResponseWriter, r *http.Request) {
+	data := app.newTemplateData(r)
+	data.Form = snippetCreateForm{
+		Visibility:   models.VisibilityUnlisted, // default values
+		Expires:      "7",
+		ExpiresValue: 1,
+		ExpiresUnit:  "hours",
+		MaxViews:     1,
+	}
+
+	app.render(w, http.StatusOK, "create_encrypted.tmpl", data)
+}
+
+// Stores an encrypted snippet sent as JSON by the browser, responding with the
+// URL of the new snippet. The key needed to decrypt the content is never sent
+// to the server; the browser adds it to the URL as a fragment.
+// NOTE: like any other POST request, it must carry the CSRF token, which the
+// browser sends in the X-CSRF-Token header.
+func (app *application) snippetCreateEncryptedPost(w http.ResponseWriter, r *http.Request) {
+	var form snippetEncryptedForm
+
+	err := decodeJSON(w, r, &form, maxEncryptedBody)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	form.CheckField(validCiphertext(form.Content), "content", "This field must be AES-GCM ciphertext of at most 65,535 bytes")
+	form.CheckField(form.Language == "" || validator.PermittedValue(form.Language, languages...), "language", "This field must be one of the listed languages")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must equal public, unlisted or private")
+	expires, ok := parseExpiry(form.Expires, form.ExpiresValue, form.ExpiresUnit)
+	form.CheckField(ok && (expires == 0 || validator.Between(expires, minExpiry, maxExpiry)), "expires", "This field must be between 10 minutes and 5 years, or never")
+	form.CheckField(validator.Between(form.MaxViews, 0, maxViews), "max_views", fmt.Sprintf("This field must be between 0 and %d", maxViews))
+
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": form.FieldErrors})
+		return
+	}
+
+	// NOTE: the language can't be detected from the ciphertext, so it's only
+	// used for the download filename when none was picked.
+	snippet := &models.Snippet{
+		Title:          form.Title,
+		Content:        form.Content,
+		Language:       form.Language,
+		Visibility:     form.Visibility,
+		ViewsRemaining: form.MaxViews,
+		Encrypted:      true,
+		UserID:         app.sessionManager.GetInt(r.Context(), "authenticatedUserID"),
+	}
+
+	shortID, err := app.snippets.Insert(snippet, expires, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet created successfully!")
+
+	app.writeJSON(w, http.StatusCreated, map[string]string{
+		"id":  shortID,
+		"url": "/snippet/view/" + shortID,
+	})
+}
+
+// The largest view limit a snippet can be created with.
+const maxViews = 1000
+
+// Represent the form data and validation errors for editing an existing snippet.
+// The expiry of a snippet is fixed when it's created, so it can't be edited.
+type snippetEditForm struct {
+	Title               string `form:"title"`
+	Filename            string `form:"filename"` // of the first file
+	Content             string `form:"content"`
+	Language            string `form:"language"` // empty to auto-detect
+	Tags                string `form:"tags"`     // separated by commas or spaces
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// Check the fields of the edit form of snippet, with the same checks as when
+// it was created, adding any errors to its Validator. Returns its tags.
+// Shared by the edit form and the API.
+func (f *snippetEditForm) validate(snippet *models.Snippet) []string {
+	f.CheckField(validator.NotBlank(f.Title), "title", "This field cannot be blank")
+	f.CheckField(validator.MaxChars(f.Title, 100), "title", "This field cannot be more than 100 characters long")
+	f.CheckField(validator.NotBlank(f.Content), "content", "This field cannot be blank")
+	f.CheckField(f.Language == "" || validator.PermittedValue(f.Language, languages...), "language", "This field must be one of the listed languages")
+	tags := parseTags(f.Tags)
+	checkTags(&f.Validator, tags)
+	f.CheckField(validator.PermittedValue(f.Visibility, models.VisibilityPublic, models.VisibilityUnlisted, models.VisibilityPrivate), "visibility", "This field must equal public, unlisted or private")
+	// Only the first file can be edited, but its filename mustn't clash with the others.
+	f.CheckField(len(snippet.Files) == 0 || validator.NotBlank(f.Filename), "filename", "This field cannot be blank when there are several files")
+	f.CheckField(f.Filename == "" || validFilename(f.Filename), "filename", "This field must be a filename of at most 255 characters, without slashes")
+	for _, file := range snippet.Files {
+		f.CheckField(f.Filename != file.Filename, "filename", "Another file already has this filename")
+	}
+
+	return tags
+}
+
+// Copy the fields of a valid edit form onto snippet.
+func (f *snippetEditForm) apply(snippet *models.Snippet, tags []string) {
+	if f.Language == "" {
+		f.Language = detectFileLanguage(f.Filename, f.Content)
+	}
+
+	snippet.Title = f.Title
+	snippet.Filename = f.Filename
+	snippet.Content = f.Content
+	snippet.Language = f.Language
+	snippet.Tags = tags
+	snippet.Visibility = f.Visibility
+}
+
+// Render the html form for editing a snippet, prefilled with its current data.
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !editable(snippet) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Filename:   snippet.Filename,
+		Content:    snippet.Content,
+		Language:   snippet.Language,
+		Tags:       strings.Join(snippet.Tags, " "),
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, http.StatusOK, "edit.tmpl", data)
+}
+
+// Processes the edit form of a snippet. Only the owner of a snippet may
+// change it; anyone else receives a 403 Forbidden response.
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	if !editable(snippet) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var form snippetEditForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	tags := form.validate(snippet)
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl", data)
+		return
+	}
+
+	form.apply(snippet, tags)
+
+	err = app.snippets.Update(snippet)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	// Call newTemplateData() helper to get a templateData struct containing the
-	// 'default' data (for now curr year) and add the snippets slice to it.
-	data := app.newTemplateData(r)
-	data.Snippets = snippets
+	app.sessionManager.Put(r.Context(), "flash", "Snippet updated successfully!")
 
-	// Pass data to the render() helper
-	app.render(w, http.StatusOK, "home.tmpl", data)
+	http.Redirect(w, r, "/snippet/view/"+snippet.ShortID, http.StatusSeeOther)
 }
 
-// Handler
-func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	// When parsing a request, any named parameters will be stored in the request context.
-	params := httprouter.ParamsFromContext(r.Context())
+// Stars a snippet for the authenticated user, adding it to their favorites.
+// Any snippet the user can see can be starred.
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return
+	}
 
-	// Instead, use ByName() to get the value of id named parameter from the slice
-	// and validate it as normal.
-	id, err := strconv.Atoi(params.ByName("id"))
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err := app.stars.Star(userID, snippet.ID)
 	if err != nil {
-		app.notFound(w)
+		app.serverError(w, err)
 		return
 	}
 
-	// Use SnippetModel's Get method to retrieve the data for a specific
-	// record based on its ID. If no record is found, return a 404 Not Found response.
-	snippet, err := app.snippets.Get(id)
-	if err != nil {
-		if errors.Is(err, models.ErrNoRecord) {
-			app.notFound(w)
-		} else {
-			app.serverError(w, err)
-		}
+	app.sessionManager.Put(r.Context(), "flash", "Snippet added to your favorites!")
+
+	http.Redirect(w, r, "/snippet/view/"+snippet.ShortID, http.StatusSeeOther)
+}
+
+// Removes the authenticated user's star from a snippet.
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
 		return
 	}
 
-	/*
-		// Retrieve and remove our flash message from the sessionManager
-		flash := app.sessionManager.PopString(r.Context(), "flash")
-	*/
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
 
-	data := app.newTemplateData(r)
-	data.Snippet = snippet
+	err := app.stars.Unstar(userID, snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
 
-	/*
-		// Now pass the flash message to the template
-		data.Flash = flash
-	*/
+	app.sessionManager.Put(r.Context(), "flash", "Snippet removed from your favorites.")
 
-	// Use our new render helper.
-	app.render(w, http.StatusOK, "view.tmpl", data)
+	http.Redirect(w, r, "/snippet/view/"+snippet.ShortID, http.StatusSeeOther)
 }
 
-// Render the html form from the GET method.
-func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
-	// w.Write([]byte("Display the form for creating a new snippet here..."))
-	data := app.newTemplateData(r)
+// Represent the form data and validation errors for commenting on a snippet,
+// or replying to a comment.
+type commentForm struct {
+	Body                string `form:"body"`
+	Line                int    `form:"line"`   // 0 for a comment on the whole snippet
+	Parent              int    `form:"parent"` // the comment replied to, or 0 to start a thread
+	validator.Validator `form:"-"`
+}
 
-	// NOTE: init a new createSnippetForm instance and pass it to the template.
-	// Otherwise, upon visiting /snippet/create Go would try to eval some tmpl tag
-	// such as .Form.FieldErrors.title which would be nil
-	data.Form = snippetCreateForm{
-		Expires: 365, // default value
+// Adds a comment to a snippet, starting a thread or replying to a comment.
+// Anyone logged in who can read the snippet's content can comment on it.
+func (app *application) snippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readSnippetContent(w, r)
+	if !ok {
+		return
 	}
 
-	app.render(w, http.StatusOK, "create.tmpl", data)
-}
+	var form commentForm
 
-// Processes and uses form data of snippet. Upon completion
-// redirects user to the view page to view their snippet.
-func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	/*
-		// Step 1: parse request body
-		err := r.ParseForm()
-		if err != nil {
-			app.clientError(w, http.StatusBadRequest)
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, models.MaxCommentLength), "body", fmt.Sprintf("This field cannot be more than %d characters long", models.MaxCommentLength))
+
+	if form.Parent != 0 {
+		parent, err := app.comments.Get(form.Parent)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
 			return
 		}
-	*/
-	var form snippetCreateForm // zero-valued instance of snippetCreateForm struct.
 
-	// Step 2: retrieve PostForm() data. Expires is a integer
-	// so convert with strconv.Atoi() and throw an error is fail
-	// conversion.
-	/*
-		expires, err := strconv.Atoi(r.PostForm.Get("expires"))
+		if err != nil || parent.SnippetID != snippet.ID {
+			form.Parent = 0
+			form.AddNonFieldError("The comment you replied to has been deleted, so yours will start a new thread")
+		}
+
+		// A reply belongs to the thread of the comment it replies to, so only
+		// the comment starting a thread is attached to a line.
+		form.Line = 0
+	}
+
+	// The server can't read encrypted snippets, so can't show comments under their lines.
+	form.CheckField(form.Line == 0 || !snippet.Encrypted, "line", "Comments on an encrypted snippet can't be attached to a line")
+	form.CheckField(validator.Between(form.Line, 0, lineCount(snippet.Content)), "line", "This field must be the number of a line of the snippet, or left empty")
+
+	if !form.Valid() {
+		data, err := app.snippetData(r, snippet, false)
 		if err != nil {
-			app.clientError(w, http.StatusBadRequest)
+			app.serverError(w, err)
 			return
 		}
+		data.Form = form
 
-		form := snippetCreateForm{
-			Title:   r.PostForm.Get("title"),
-			Content: r.PostForm.Get("content"),
-			Expires: expires,
-		}
-	*/
+		app.render(w, http.StatusUnprocessableEntity, "view.tmpl", data)
+		return
+	}
+
+	id, err := app.comments.Insert(&models.Comment{
+		SnippetID: snippet.ID,
+		UserID:    app.sessionManager.GetInt(r.Context(), "authenticatedUserID"),
+		ParentID:  form.Parent,
+		Line:      form.Line,
+		Body:      form.Body,
+	})
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment posted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", snippet.ShortID, id), http.StatusSeeOther)
+}
+
+// Represent the form data and validation errors for editing a comment.
+type commentEditForm struct {
+	Body                string `form:"body"`
+	validator.Validator `form:"-"`
+}
+
+// Render the html form for editing a comment, prefilled with its current body.
+func (app *application) commentEdit(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Comment = comment
+	data.Form = commentEditForm{Body: comment.Body}
+
+	app.render(w, http.StatusOK, "comment_edit.tmpl", data)
+}
+
+// Processes the edit form of a comment. Only the author of a comment may
+// change it; anyone else receives a 403 Forbidden response.
+func (app *application) commentEditPost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentEditForm
 
-	// Use our helper to handle nil panic.
 	err := app.decodePostForm(r, &form)
 	if err != nil {
 		app.clientError(w, http.StatusBadRequest)
 		return
 	}
 
-	// Call CheckField to execute our validation checks.
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	// Use generic PermittedValue() instead of type-specific PermittedInt().
-	form.CheckField(validator.PermittedValue(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7, or 365")
+	// Same checks as when the comment was posted.
+	form.CheckField(validator.NotBlank(form.Body), "body", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Body, models.MaxCommentLength), "body", fmt.Sprintf("This field cannot be more than %d characters long", models.MaxCommentLength))
 
-	// Use Valid() to see if any checks failed.
-	// If so, re-render passing in the form as before.
 	if !form.Valid() {
-		// re-render
 		data := app.newTemplateData(r)
+		data.Comment = comment
 		data.Form = form
-		app.render(w, http.StatusUnprocessableEntity, "create.tmpl", data)
+		app.render(w, http.StatusUnprocessableEntity, "comment_edit.tmpl", data)
 		return
 	}
 
-	// insert title, content, expiration into db
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	err = app.comments.Update(comment.ID, form.Body)
 	if err != nil {
 		app.serverError(w, err)
 		return
 	}
 
-	// NOTE: Use Put() to add a string value and corresponding flash key to session data.
-	app.sessionManager.Put(r.Context(), "flash", "Snippet created successfully!")
+	app.sessionManager.Put(r.Context(), "flash", "Comment updated successfully!")
 
-	// w.Write([]byte("Create a new snippet...\"))
-	// Redirect the user to the relevant page for the snippet.
-	// Update redirect path to the new clean URL format.
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", comment.SnippetShortID, comment.ID), http.StatusSeeOther)
+}
+
+// Deletes a comment, along with the replies to it. Only the author of a
+// comment may delete it; anyone else receives a 403 Forbidden response.
+func (app *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.ownedComment(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.comments.Delete(comment.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment deleted successfully!")
+
+	http.Redirect(w, r, "/snippet/view/"+comment.SnippetShortID+"#comments", http.StatusSeeOther)
+}
+
+// Deletes a snippet before it expires. Only the owner of a snippet may
+// delete it; anyone else receives a 403 Forbidden response.
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet deleted successfully!")
+
+	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
 // Hold form data for the user signup.
@@ -345,7 +1435,7 @@ type accountPasswordUpdateForm struct {
 }
 
 // Handler to display an HTML form containing an authenticated
-// user's name, email, and join date.
+// user's name, email, and join date, along with the snippets they own.
 func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
 	// 1) get the authenticatedUserID from the session
 	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
@@ -362,13 +1452,37 @@ func (app *application) accountView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Also list the snippets the user has created.
+	snippets, err := app.snippets.ByUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// Render the account template
 	data := app.newTemplateData(r)
 	data.User = user // use the user data to fill out the template
+	data.Snippets = snippets
 
 	app.render(w, http.StatusOK, "account.tmpl", data)
 }
 
+// Lists the unexpired snippets the authenticated user has starred.
+func (app *application) accountFavorites(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	snippets, err := app.stars.Favorites(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "favorites.tmpl", data)
+}
+
 // Handler for displaying an HTML form
 // for allowing a user to change their password.
 func (app *application) accountPasswordUpdate(w http.ResponseWriter, r *http.Request) {
@@ -427,6 +1541,98 @@ func (app *application) accountPasswordUpdatePost(w http.ResponseWriter, r *http
 	http.Redirect(w, r, "/account/view", http.StatusSeeOther)
 }
 
+// Represents the form data and validation errors for creating an API token.
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	Scope               string `form:"scope"`
+	validator.Validator `form:"-"`
+}
+
+// Lists the API tokens of the authenticated user, with a form to create another.
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{Scope: models.ScopeRead}, "")
+}
+
+// Creates an API token for the authenticated user.
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, models.MaxTokenNameLength), "name", fmt.Sprintf("This field cannot be more than %d characters long", models.MaxTokenNameLength))
+	form.CheckField(validator.PermittedValue(form.Scope, models.ScopeRead, models.ScopeWrite), "scope", "This field must equal read or write")
+
+	if !form.Valid() {
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	token, err := app.tokens.Insert(userID, form.Name, form.Scope)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// NOTE: the new token is shown on the page rendered here, rather than after
+	// a redirect, which would need it kept in the session until then. Sessions
+	// are stored in the database, where only a hash of the token may be.
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{Scope: models.ScopeRead}, token)
+}
+
+// Render the tokens page, listing the API tokens of the authenticated user,
+// with the form to create one, and newToken if one was just created.
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm, newToken string) {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	tokens, err := app.tokens.ByUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.Form = form
+	data.NewToken = newToken
+
+	app.render(w, status, "tokens.tmpl", data)
+}
+
+// Revokes an API token of the authenticated user. Tokens of other users
+// can't be revoked, and get a 404 Not Found response like missing ones.
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+
+	err = app.tokens.Delete(userID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token revoked successfully!")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
+
 // NOTE: miscellaneous handlers
 
 // Handler to display an HTML form of the about page.
@@ -1,11 +1,18 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"snippetbox.adpollak.net/internal/assert"
+	"snippetbox.adpollak.net/internal/models/mocks"
 )
 
 func TestPing(t *testing.T) {
@@ -37,33 +44,1610 @@ func TestSnippetView(t *testing.T) {
 	}{
 		{
 			name:     "Valid ID",
-			urlPath:  "/snippet/view/1",
+			urlPath:  "/snippet/view/oldPond1",
 			wantCode: http.StatusOK,
 			wantBody: "An old silent pond...",
 		},
+		{
+			name:     "Shows author",
+			urlPath:  "/snippet/view/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "by Alice",
+		},
+		{
+			name:     "Numbered lines",
+			urlPath:  "/snippet/view/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "<span id='L1' class='line'><a class='ln' href='#L1'>1</a>",
+		},
+		{
+			name:     "Highlighted lines",
+			urlPath:  "/snippet/view/oldPond1?lines=L1-L3",
+			wantCode: http.StatusOK,
+			wantBody: "<span id='L1' class='line hl'>",
+		},
+		{
+			name:     "Forked from",
+			urlPath:  "/snippet/view/forkPond",
+			wantCode: http.StatusOK,
+			wantBody: "forked from <a href='/snippet/view/oldPond1'>#oldPond1</a>",
+		},
+		{
+			name:     "Fork count",
+			urlPath:  "/snippet/view/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "<small>1 fork</small>",
+		},
+		{
+			name:     "Tags",
+			urlPath:  "/snippet/view/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/tag/haiku' class='tag'>haiku</a>",
+		},
+		{
+			name:     "Multiple files",
+			urlPath:  "/snippet/view/twoFiles",
+			wantCode: http.StatusOK,
+			wantBody: "<div class='filename'>go.mod</div>",
+		},
 		{
 			name:     "Non-existent ID",
+			urlPath:  "/snippet/view/noSnip99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Non-existent legacy ID",
 			urlPath:  "/snippet/view/2",
 			wantCode: http.StatusNotFound,
 		},
 		{
-			name:     "Negative ID",
-			urlPath:  "/snippet/view/-1",
-			wantCode: http.StatusNotFound,
+			name:     "Negative ID",
+			urlPath:  "/snippet/view/-1",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Decimal ID",
+			urlPath:  "/snippet/view/1.2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "String ID",
+			urlPath:  "/snippet/view/bar",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Empty ID",
+			urlPath:  "/snippet/view/",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		urlPath         string
+		wantCode        int
+		wantBody        string
+		wantDisposition string
+	}{
+		{
+			name:     "Raw",
+			urlPath:  "/snippet/raw/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "An old silent pond...",
+		},
+		{
+			name:            "Download",
+			urlPath:         "/snippet/download/oldPond1",
+			wantCode:        http.StatusOK,
+			wantBody:        "An old silent pond...",
+			wantDisposition: "attachment; filename=An-old-silent-pond.txt",
+		},
+		{
+			name:            "Download first file",
+			urlPath:         "/snippet/download/twoFiles",
+			wantCode:        http.StatusOK,
+			wantBody:        "package main\n\nfunc main() {}\n",
+			wantDisposition: "attachment; filename=main.go",
+		},
+		{
+			name:     "Raw non-existent ID",
+			urlPath:  "/snippet/raw/2",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Download non-existent ID",
+			urlPath:  "/snippet/download/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Content-Disposition"), tt.wantDisposition)
+
+			if tt.wantBody != "" {
+				assert.Equal(t, body, tt.wantBody)
+				assert.Equal(t, headers.Get("Content-Type"), "text/plain; charset=utf-8")
+				assert.Equal(t, headers.Get("Cache-Control"), "no-cache")
+			}
+		})
+	}
+}
+
+func TestSnippetDownloadArchive(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	wantFiles := map[string]string{
+		"main.go": "package main\n\nfunc main() {}\n",
+		"go.mod":  "module example.com/hello\n",
+	}
+
+	t.Run("Zip", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/download/twoFiles/archive")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/zip")
+		assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=Hello--world.zip")
+
+		zr, err := zip.NewReader(strings.NewReader(body), int64(len(body)))
+		assert.NilError(t, err)
+		assert.Equal(t, len(zr.File), len(wantFiles))
+
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			assert.NilError(t, err)
+			content, err := io.ReadAll(rc)
+			assert.NilError(t, err)
+			rc.Close()
+
+			assert.Equal(t, string(content), wantFiles[f.Name])
+		}
+	})
+
+	t.Run("Tar gz", func(t *testing.T) {
+		code, headers, body := ts.get(t, "/snippet/download/twoFiles/archive?format=tar.gz")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Content-Type"), "application/gzip")
+		assert.Equal(t, headers.Get("Content-Disposition"), "attachment; filename=Hello--world.tar.gz")
+
+		gr, err := gzip.NewReader(strings.NewReader(body))
+		assert.NilError(t, err)
+		tr := tar.NewReader(gr)
+
+		n := 0
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NilError(t, err)
+			content, err := io.ReadAll(tr)
+			assert.NilError(t, err)
+
+			assert.Equal(t, string(content), wantFiles[hdr.Name])
+			n++
+		}
+		assert.Equal(t, n, len(wantFiles))
+	})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{
+			name:     "Single file",
+			urlPath:  "/snippet/download/oldPond1/archive",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "Unknown format",
+			urlPath:  "/snippet/download/twoFiles/archive?format=rar",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/download/noSnip99/archive",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/download/burnNote/archive",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetArchive(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippets")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/snippet/view/oldPond1'>An old silent pond</a>")
+
+	code, _, body = ts.get(t, "/snippets?after=1")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "Nothing to see here... yet!")
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
+	}{
+		{
+			name:     "Empty query",
+			urlPath:  "/search",
+			wantBody: "<input type='search' name='q' value='' placeholder='Search snippets'>",
+		},
+		{
+			name:     "Match",
+			urlPath:  "/search?q=pond",
+			wantBody: "<a href='/snippet/view/oldPond1'>An old silent <mark>pond</mark></a>",
+		},
+		{
+			name:     "No match",
+			urlPath:  "/search?q=frog",
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Past the last page",
+			urlPath:  "/search?q=pond&page=2",
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Match within tag",
+			urlPath:  "/search?q=pond&tag=haiku",
+			wantBody: "<a href='/snippet/view/oldPond1'>An old silent <mark>pond</mark></a>",
+		},
+		{
+			name:     "No match within tag",
+			urlPath:  "/search?q=pond&tag=sql",
+			wantBody: "No snippets match your search.",
+		},
+		{
+			name:     "Tag without query",
+			urlPath:  "/search?tag=Haiku",
+			wantBody: "<a href='/snippet/view/oldPond1'>An old silent pond</a>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+// With encryption at rest, search only matches titles, and says so.
+func TestSearchEncryptedAtRest(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippets = &mocks.SnippetModel{EncryptedAtRest: true}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantBody string
+	}{
+		{
+			name:     "Notice",
+			urlPath:  "/search",
+			wantBody: "only their titles are searched, not their content",
+		},
+		{
+			name:     "Title match",
+			urlPath:  "/search?q=pond",
+			wantBody: "<a href='/snippet/view/oldPond1'>An old silent <mark>pond</mark></a>",
+		},
+		{
+			name:     "Content only match",
+			urlPath:  "/search?q=pond...",
+			wantBody: "No snippets match your search.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, http.StatusOK)
+			assert.StringContains(t, body, tt.wantBody)
+		})
+	}
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "History",
+			urlPath:  "/snippet/view/oldPond1/history",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/oldPond1/history/2'>#2</a>",
+		},
+		{
+			name:     "History of non-existent ID",
+			urlPath:  "/snippet/view/2/history",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Old revision",
+			urlPath:  "/snippet/view/oldPond1/history/1",
+			wantCode: http.StatusOK,
+			wantBody: "A frog jumps in,",
+		},
+		{
+			name:     "Non-existent revision",
+			urlPath:  "/snippet/view/oldPond1/history/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Diff with previous",
+			urlPath:  "/snippet/view/oldPond1/diff",
+			wantCode: http.StatusOK,
+			wantBody: "<pre>&#43;A frog jumps into the pond,</pre>",
+		},
+		{
+			name:     "Diff between revisions",
+			urlPath:  "/snippet/view/oldPond1/diff?from=2&to=1",
+			wantCode: http.StatusOK,
+			wantBody: "<pre>-A frog jumps into the pond,</pre>",
+		},
+		{
+			name:     "Diff from empty",
+			urlPath:  "/snippet/view/oldPond1/diff?to=1",
+			wantCode: http.StatusOK,
+			wantBody: "<pre>&#43;An old silent pond...</pre>",
+		},
+		{
+			name:     "Diff with invalid revision",
+			urlPath:  "/snippet/view/oldPond1/diff?from=foo",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Diff with non-existent revision",
+			urlPath:  "/snippet/view/oldPond1/diff?from=1&to=5",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	// NOTE: STEP 1: Initialize a new test server using app
+	// routes and mocked dependencies
+	app := newTestApplication(t)
+
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// NOTE: STEP 2: Create a sub-test w/ name "Unauthenticated".
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/create")
+
+		// Verify response has status code 302
+		assert.Equal(t, code, http.StatusSeeOther)
+
+		// Verify response has Location: /user/login header.
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		// NOTE: Step 3: Create sub-test Authenticated.
+		// Mimic workflow of user logging in to authenticate.
+
+		// a) make a GET /user/login request
+		_, _, body := ts.get(t, "/user/login")
+		// b) extract CSRF token from response body
+		validCSRFToken := extractCSRFToken(t, body)
+
+		// c) make a POST /user/login request using CSRF token and credentials
+		// in mock user model
+		form := url.Values{}
+		form.Add("name", "alice")
+		form.Add("email", "alice@example.com")
+		form.Add("password", "pa$$word")
+		form.Add("csrf_token", validCSRFToken)
+		ts.postForm(t, "/user/login", form)
+
+		// d) Make a GET /snippet/create request and verify
+		// receive 200 and HTML body
+		code, _, body := ts.get(t, "/snippet/create")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
+	})
+}
+
+func TestSnippetLegacyRedirect(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "View",
+			urlPath:      "/snippet/view/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/oldPond1",
+		},
+		{
+			name:         "Query string",
+			urlPath:      "/snippet/view/1?lines=L1-L3",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/oldPond1?lines=L1-L3",
+		},
+		{
+			name:         "Revision",
+			urlPath:      "/snippet/view/1/history/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/view/oldPond1/history/1",
+		},
+		{
+			name:         "Raw",
+			urlPath:      "/snippet/raw/1",
+			wantCode:     http.StatusMovedPermanently,
+			wantLocation: "/snippet/raw/oldPond1",
+		},
+		{
+			// Only snippets created before short IDs can be found by their integer ID.
+			name:     "Not legacy",
+			urlPath:  "/snippet/view/3",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			// Redirecting would reveal the short ID of the private snippet.
+			name:     "Private",
+			urlPath:  "/snippet/view/4",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, headers, _ := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	t.Run("POST", func(t *testing.T) {
+		ts.login(t)
+
+		_, _, body := ts.get(t, "/snippet/view/oldPond1")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, headers, _ := ts.postForm(t, "/snippet/delete/1", form)
+
+		assert.Equal(t, code, http.StatusPermanentRedirect)
+		assert.Equal(t, headers.Get("Location"), "/snippet/delete/oldPond1")
+	})
+}
+
+func TestSnippetViewPrivate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	// Private snippets are hidden from everyone but their owner, including
+	// on the raw and history pages.
+	for _, urlPath := range []string{"/snippet/view/smrRiver", "/snippet/raw/smrRiver", "/snippet/view/smrRiver/history"} {
+		t.Run("Unauthenticated "+urlPath, func(t *testing.T) {
+			code, _, _ := ts.get(t, urlPath)
+
+			assert.Equal(t, code, http.StatusNotFound)
+		})
+	}
+
+	ts.login(t)
+
+	t.Run("Owner", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/smrRiver")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<small class='badge'>private</small>")
+	})
+
+	t.Run("Owner raw", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/raw/smrRiver")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, headers.Get("Cache-Control"), "private, no-cache")
+	})
+}
+
+func TestSnippetReveal(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Confirmation", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/burnNote")
+
+		// The content isn't shown until the reader confirms.
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Are you sure you want to reveal it?")
+		assert.Equal(t, strings.Contains(body, "hunter2"), false)
+	})
+
+	// Anywhere else the content could be read from is hidden.
+	for _, urlPath := range []string{"/snippet/raw/burnNote", "/snippet/download/burnNote", "/snippet/view/burnNote/history"} {
+		t.Run(urlPath, func(t *testing.T) {
+			code, _, _ := ts.get(t, urlPath)
+
+			assert.Equal(t, code, http.StatusNotFound)
+		})
+	}
+
+	_, _, body := ts.get(t, "/snippet/view/burnNote")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		urlPath   string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Content",
+			urlPath:   "/snippet/view/burnNote",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusOK,
+			wantBody:  "hunter2",
+		},
+		{
+			name:      "Deleted notice",
+			urlPath:   "/snippet/view/burnNote",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusOK,
+			wantBody:  "This snippet has now been deleted",
+		},
+		{
+			name:      "Invalid CSRF token",
+			urlPath:   "/snippet/view/burnNote",
+			csrfToken: "wrongToken",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "Non-existent ID",
+			urlPath:   "/snippet/view/noSnip99",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetUnlock(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/lockedUp")
+
+	// The content isn't shown until the snippet is unlocked.
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<form action='/snippet/unlock/lockedUp' method='POST' novalidate data-keep-fragment>")
+	assert.Equal(t, strings.Contains(body, "Forty thieves"), false)
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Locked raw", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/raw/lockedUp")
+
+		assert.Equal(t, code, http.StatusForbidden)
+	})
+
+	tests := []struct {
+		name      string
+		password  string
+		csrfToken string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Invalid CSRF token",
+			password:  "open sesame",
+			csrfToken: "wrongToken",
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "Empty password",
+			password:  "",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "This field cannot be blank",
+		},
+		{
+			name:      "Wrong password",
+			password:  "close sesame",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "Password is incorrect",
+		},
+		{
+			name:      "Right password",
+			password:  "open sesame",
+			csrfToken: validCSRFToken,
+			wantCode:  http.StatusSeeOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("password", tt.password)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/snippet/unlock/lockedUp", form)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	// The snippet stays unlocked for the rest of the session.
+	t.Run("Unlocked", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/lockedUp")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "Forty thieves")
+	})
+
+	t.Run("Unlocked raw", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/raw/lockedUp")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.Equal(t, body, "Forty thieves")
+	})
+}
+
+func TestSnippetUnlockLimit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/lockedUp")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "close sesame")
+	form.Add("csrf_token", validCSRFToken)
+
+	for range maxUnlockAttempts {
+		code, _, _ := ts.postForm(t, "/snippet/unlock/lockedUp", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// Once the client has run out of attempts, even the right password is refused.
+	form.Set("password", "open sesame")
+
+	code, _, body := ts.postForm(t, "/snippet/unlock/lockedUp", form)
+
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many failed attempts")
+}
+
+// Attempts from every client count towards the limit of the snippet.
+func TestSnippetUnlockSnippetLimit(t *testing.T) {
+	app := newTestApplication(t)
+	app.snippetUnlockLimiter = newAttemptLimiter(2, unlockWindow)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	_, _, body := ts.get(t, "/snippet/view/lockedUp")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("password", "close sesame")
+	form.Add("csrf_token", validCSRFToken)
+
+	for range 2 {
+		code, _, _ := ts.postForm(t, "/snippet/unlock/lockedUp", form)
+		assert.Equal(t, code, http.StatusUnprocessableEntity)
+	}
+
+	// The client has attempts left, but the snippet doesn't.
+	form.Set("password", "open sesame")
+
+	code, _, body := ts.postForm(t, "/snippet/unlock/lockedUp", form)
+
+	assert.Equal(t, code, http.StatusTooManyRequests)
+	assert.StringContains(t, body, "Too many failed attempts")
+}
+
+func TestSnippetViewEncrypted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/snippet/view/encNote7")
+
+	// Only the ciphertext is sent, for the browser to decrypt.
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<code data-ciphertext='AAECAwQFBgcICQoLfd9nGGCgo4z2bHe4p1I0ynDv0kvDTXmS'></code>")
+	assert.StringContains(t, body, "<script src='/static/js/encrypted.js' type='module'></script>")
+}
+
+func TestSnippetCreateEncrypted(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/snippet/create/encrypted")
+
+	// The content field has no name, so it's never submitted as a form.
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<textarea id='plaintext'></textarea>")
+
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		csrfToken string
+		body      string
+		wantCode  int
+		wantBody  string
+	}{
+		{
+			name:      "Valid ciphertext",
+			csrfToken: validCSRFToken,
+			body:      `{"title": "A secret", "content": "AAECAwQFBgcICQoLfd9nGGCgo4z2bHe4p1I0ynDv0kvDTXmS", "visibility": "unlisted", "expires": "7", "max_views": 1}`,
+			wantCode:  http.StatusCreated,
+			wantBody:  `{"id":"newSnip2","url":"/snippet/view/newSnip2"}`,
+		},
+		{
+			name:      "Plaintext",
+			csrfToken: validCSRFToken,
+			body:      `{"title": "A secret", "content": "not encrypted!", "visibility": "unlisted", "expires": "7"}`,
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  `"content":"This field must be AES-GCM ciphertext of at most 65,535 bytes"`,
+		},
+		{
+			name:      "Unknown field",
+			csrfToken: validCSRFToken,
+			body:      `{"title": "A secret", "key": "c2VjcmV0"}`,
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "Missing CSRF token",
+			csrfToken: "",
+			body:      `{"title": "A secret", "content": "AAECAwQFBgcICQoLfd9nGGCgo4z2bHe4p1I0ynDv0kvDTXmS", "visibility": "unlisted", "expires": "7"}`,
+			wantCode:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Content-Type", "application/json")
+			header.Set("X-CSRF-Token", tt.csrfToken)
+
+			code, _, body := ts.request(t, http.MethodPost, "/snippet/create/encrypted", header, strings.NewReader(tt.body))
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("Can't be edited", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/edit/encNote7")
+
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
+
+func TestSnippetEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/edit/oldPond1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Owner",
+			urlPath:  "/snippet/edit/oldPond1",
+			wantCode: http.StatusOK,
+			wantBody: "<form action='/snippet/edit/oldPond1' method='POST'>",
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/edit/wntrFrst",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/edit/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/snippet/edit/oldPond1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	postTests := []struct {
+		name     string
+		urlPath  string
+		title    string
+		wantCode int
+	}{
+		{
+			name:     "Valid submission",
+			urlPath:  "/snippet/edit/oldPond1",
+			title:    "A new title",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Empty title",
+			urlPath:  "/snippet/edit/oldPond1",
+			title:    "",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/edit/wntrFrst",
+			title:    "A new title",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range postTests {
+		t.Run("POST "+tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", "Some new content")
+			form.Add("visibility", "unlisted")
+			form.Add("csrf_token", validCSRFToken)
+
+			code, _, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+		})
+	}
+}
+
+func TestSnippetComments(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/view/oldPond1")
+
+		// The thread on the first line is shown beneath it, with its reply.
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<span class='line-comments'>")
+		assert.StringContains(t, body, "<div class='body'>Is it still silent after the splash?</div>")
+		assert.StringContains(t, body, "<div class='body'>Silence again, says the last line.</div>")
+		assert.StringContains(t, body, "<div class='body'>A classic.</div>")
+		assert.StringContains(t, body, "<small>(edited)</small>")
+		assert.StringContains(t, body, "<a href='/user/login'>Log in</a> to comment.")
+	})
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/oldPond1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Authenticated", func(t *testing.T) {
+		// Only the comments of the user can be edited.
+		assert.StringContains(t, body, "<a href='/comment/edit/1'>Edit</a>")
+		assert.Equal(t, strings.Contains(body, "<a href='/comment/edit/3'>Edit</a>"), false)
+		assert.StringContains(t, body, "<input type='hidden' name='parent' value='3'>")
+		assert.StringContains(t, body, "<form action='/snippet/comment/oldPond1' method='POST' class='comment-form'>")
+	})
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		line         string
+		parent       string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid comment",
+			urlPath:      "/snippet/comment/oldPond1",
+			body:         "Lovely",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1#comment-4",
+		},
+		{
+			name:         "Line comment",
+			urlPath:      "/snippet/comment/oldPond1",
+			body:         "Lovely",
+			line:         "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1#comment-4",
+		},
+		{
+			name:         "Reply",
+			urlPath:      "/snippet/comment/oldPond1",
+			body:         "Agreed",
+			parent:       "3",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1#comment-4",
+		},
+		{
+			name:     "Blank comment",
+			urlPath:  "/snippet/comment/oldPond1",
+			body:     " ",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Too long",
+			urlPath:  "/snippet/comment/oldPond1",
+			body:     strings.Repeat("a", 2001),
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Line past the end",
+			urlPath:  "/snippet/comment/oldPond1",
+			body:     "Lovely",
+			line:     "2",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Deleted parent",
+			urlPath:  "/snippet/comment/oldPond1",
+			body:     "Agreed",
+			parent:   "99",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Parent on another snippet",
+			urlPath:  "/snippet/comment/wntrFrst",
+			body:     "Agreed",
+			parent:   "3",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Line of an encrypted snippet",
+			urlPath:  "/snippet/comment/encNote7",
+			body:     "Lovely",
+			line:     "1",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/comment/notThere",
+			body:     "Lovely",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("line", tt.line)
+			form.Add("parent", tt.parent)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestCommentEdit(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/comment/edit/1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Author",
+			urlPath:  "/comment/edit/1",
+			wantCode: http.StatusOK,
+			wantBody: "<textarea name='body'>Is it still silent after the splash?</textarea>",
+		},
+		{
+			name:     "Not author",
+			urlPath:  "/comment/edit/3",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/comment/edit/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/comment/edit/one",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/comment/edit/1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	postTests := []struct {
+		name         string
+		urlPath      string
+		body         string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid submission",
+			urlPath:      "/comment/edit/1",
+			body:         "Is it?",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1#comment-1",
+		},
+		{
+			name:     "Blank comment",
+			urlPath:  "/comment/edit/1",
+			body:     "",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:     "Not author",
+			urlPath:  "/comment/edit/3",
+			body:     "Is it?",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range postTests {
+		t.Run("POST "+tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("body", tt.body)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestCommentDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/oldPond1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Author",
+			urlPath:      "/comment/delete/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1#comments",
+		},
+		{
+			name:     "Not author",
+			urlPath:  "/comment/delete/3",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/comment/delete/99",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/view/oldPond1")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Owner",
+			urlPath:      "/snippet/delete/oldPond1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/view",
+		},
+		{
+			name:     "Not owner",
+			urlPath:  "/snippet/delete/wntrFrst",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/delete/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestSnippetStar(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	// The mocked user has starred wntrFrst, but not oldPond1.
+	_, _, body := ts.get(t, "/snippet/view/oldPond1")
+	assert.StringContains(t, body, "<form action='/snippet/star/oldPond1' method='POST'>")
+	assert.StringContains(t, body, "<small class='stars'>&#9733; 0 stars</small>")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	_, _, body = ts.get(t, "/snippet/view/wntrFrst")
+	assert.StringContains(t, body, "<form action='/snippet/unstar/wntrFrst' method='POST'>")
+	assert.StringContains(t, body, "<small class='stars'>&#9733; 2 stars</small>")
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Star",
+			urlPath:      "/snippet/star/oldPond1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/oldPond1",
+		},
+		{
+			name:         "Unstar",
+			urlPath:      "/snippet/unstar/wntrFrst",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/wntrFrst",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/star/2",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+
+	t.Run("Missing CSRF token", func(t *testing.T) {
+		code, _, _ := ts.postForm(t, "/snippet/star/oldPond1", url.Values{})
+
+		assert.Equal(t, code, http.StatusBadRequest)
+	})
+}
+
+func TestAccountFavorites(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/favorites")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/account/favorites")
+
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<a href='/snippet/view/wntrFrst'>Over the wintry forest</a>")
+	})
+}
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/tokens")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	code, _, body := ts.get(t, "/account/tokens")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("List", func(t *testing.T) {
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "<td>Laptop</td>")
+		assert.StringContains(t, body, "<form action='/account/tokens/revoke/2' method='POST'>")
+		assert.Equal(t, strings.Contains(body, "<div class='new-token'>"), false)
+	})
+
+	tests := []struct {
+		name         string
+		tokenName    string
+		scope        string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:      "Valid submission",
+			tokenName: "Desktop",
+			scope:     "write",
+			wantCode:  http.StatusOK,
+			wantBody:  "<pre><code>sbx_newToken</code></pre>",
+		},
+		{
+			name:      "Blank name",
+			tokenName: "",
+			scope:     "read",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "<label class='error'>This field cannot be blank</label>",
+		},
+		{
+			name:      "Invalid scope",
+			tokenName: "Desktop",
+			scope:     "admin",
+			wantCode:  http.StatusUnprocessableEntity,
+			wantBody:  "<label class='error'>This field must equal read or write</label>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.tokenName)
+			form.Add("scope", tt.scope)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, "/account/tokens", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	t.Run("New token not in the session", func(t *testing.T) {
+		// The session store holds the sessions in production, so it must never
+		// see a token in the clear.
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		found := false
+		for _, cookie := range ts.Client().Jar.Cookies(u) {
+			if cookie.Name != app.sessionManager.Cookie.Name {
+				continue
+			}
+			session, ok, err := app.sessionManager.Store.Find(cookie.Value)
+			assert.NilError(t, err)
+			assert.Equal(t, ok, true)
+			assert.Equal(t, bytes.Contains(session, []byte("sbx_newToken")), false)
+			found = true
+		}
+		assert.Equal(t, found, true)
+	})
+
+	t.Run("New token shown once", func(t *testing.T) {
+		_, _, body := ts.get(t, "/account/tokens")
+		assert.Equal(t, strings.Contains(body, "sbx_newToken"), false)
+	})
+}
+
+func TestAccountTokenRevoke(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/account/tokens")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		urlPath      string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Own token",
+			urlPath:      "/account/tokens/revoke/1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/account/tokens",
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/account/tokens/revoke/99",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid ID",
+			urlPath:  "/account/tokens/revoke/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, _ := ts.postForm(t, tt.urlPath, form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
+}
+
+func TestAccountView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/account/view")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		ts.login(t)
+
+		code, _, body := ts.get(t, "/account/view")
+
+		// The account page lists the snippets owned by the user.
+		assert.Equal(t, code, http.StatusOK)
+		assert.StringContains(t, body, "My Snippets")
+		assert.StringContains(t, body, "<a href='/snippet/view/oldPond1'>An old silent pond</a>")
+	})
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code, _, body := ts.get(t, "/")
+
+	assert.Equal(t, code, http.StatusOK)
+	assert.StringContains(t, body, "<a href='/tag/haiku' class='size-5' title='3 snippets'>haiku</a>")
+	assert.StringContains(t, body, "<a href='/tag/nature' class='size-1' title='1 snippet'>nature</a>")
+	assert.StringContains(t, body, "Most Starred This Week")
+	assert.StringContains(t, body, "<td>&#9733; 2</td>")
+}
+
+func TestTagView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Tag",
+			urlPath:  "/tag/haiku",
+			wantCode: http.StatusOK,
+			wantBody: "<a href='/snippet/view/oldPond1'>An old silent pond</a>",
 		},
 		{
-			name:     "Decimal ID",
-			urlPath:  "/snippet/view/1.2",
-			wantCode: http.StatusNotFound,
+			name:     "Past the last page",
+			urlPath:  "/tag/haiku?after=1",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets are tagged haiku.",
 		},
 		{
-			name:     "String ID",
-			urlPath:  "/snippet/view/bar",
-			wantCode: http.StatusNotFound,
+			name:     "Unused tag",
+			urlPath:  "/tag/sql",
+			wantCode: http.StatusOK,
+			wantBody: "No snippets are tagged sql.",
 		},
 		{
-			name:     "Empty ID",
-			urlPath:  "/snippet/view/",
+			name:     "Invalid tag",
+			urlPath:  "/tag/Not_A_Tag",
 			wantCode: http.StatusNotFound,
 		},
 	}
@@ -73,7 +1657,6 @@ func TestSnippetView(t *testing.T) {
 			code, _, body := ts.get(t, tt.urlPath)
 
 			assert.Equal(t, code, tt.wantCode)
-
 			if tt.wantBody != "" {
 				assert.StringContains(t, body, tt.wantBody)
 			}
@@ -81,50 +1664,214 @@ func TestSnippetView(t *testing.T) {
 	}
 }
 
-func TestSnippetCreate(t *testing.T) {
-	// NOTE: STEP 1: Initialize a new test server using app
-	// routes and mocked dependencies
+func TestSnippetCreatePost(t *testing.T) {
 	app := newTestApplication(t)
-
 	ts := newTestServer(t, app.routes())
 	defer ts.Close()
 
-	// NOTE: STEP 2: Create a sub-test w/ name "Unauthenticated".
-	t.Run("Unauthenticated", func(t *testing.T) {
-		code, headers, _ := ts.get(t, "/snippet/create")
-
-		// Verify response has status code 302
-		assert.Equal(t, code, http.StatusSeeOther)
-
-		// Verify response has Location: /user/login header.
-		assert.Equal(t, headers.Get("Location"), "/user/login")
-	})
+	ts.login(t)
 
-	t.Run("Authenticated", func(t *testing.T) {
-		// NOTE: Step 3: Create sub-test Authenticated.
-		// Mimic workflow of user logging in to authenticate.
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
 
-		// a) make a GET /user/login request
-		_, _, body := ts.get(t, "/user/login")
-		// b) extract CSRF token from response body
-		validCSRFToken := extractCSRFToken(t, body)
+	tests := []struct {
+		name         string
+		title        string
+		content      string
+		language     string
+		visibility   string
+		expires      string
+		expiresValue string
+		expiresUnit  string
+		maxViews     string
+		password     string
+		tags         string
+		wantCode     int
+		wantLocation string
+	}{
+		{
+			name:         "Valid submission",
+			title:        "Hello",
+			content:      "package main",
+			language:     "Go",
+			visibility:   "public",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:         "Auto-detected language",
+			title:        "Hello",
+			content:      "#!/bin/bash\necho hello",
+			language:     "",
+			visibility:   "private",
+			expires:      "7",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:       "Unknown language",
+			title:      "Hello",
+			content:    "package main",
+			language:   "Klingon",
+			visibility: "public",
+			expires:    "7",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid visibility",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "secret",
+			expires:    "7",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Invalid expiry",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "public",
+			expires:    "2",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Never expires",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "public",
+			expires:      "never",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:         "Custom expiry",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "public",
+			expires:      "custom",
+			expiresValue: "30",
+			expiresUnit:  "minutes",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:         "Custom expiry too short",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "public",
+			expires:      "custom",
+			expiresValue: "5",
+			expiresUnit:  "minutes",
+			wantCode:     http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Custom expiry too long",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "public",
+			expires:      "custom",
+			expiresValue: "6",
+			expiresUnit:  "years",
+			wantCode:     http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Burn after reading",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "unlisted",
+			expires:      "7",
+			maxViews:     "1",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:         "Password",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "unlisted",
+			expires:      "7",
+			password:     "open sesame",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:       "Short password",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "unlisted",
+			expires:    "7",
+			password:   "sesame",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Negative max views",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "public",
+			expires:    "7",
+			maxViews:   "-1",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Too many max views",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "public",
+			expires:    "7",
+			maxViews:   "1001",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "Tags",
+			title:        "Hello",
+			content:      "package main",
+			visibility:   "public",
+			expires:      "7",
+			tags:         "Go, k8s c++ go",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:       "Invalid tag",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "public",
+			expires:    "7",
+			tags:       "go #hash",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "Too many tags",
+			title:      "Hello",
+			content:    "package main",
+			visibility: "public",
+			expires:    "7",
+			tags:       "a b c d e f g h i j k",
+			wantCode:   http.StatusUnprocessableEntity,
+		},
+	}
 
-		// c) make a POST /user/login request using CSRF token and credentials
-		// in mock user model
-		form := url.Values{}
-		form.Add("name", "alice")
-		form.Add("email", "alice@example.com")
-		form.Add("password", "pa$$word")
-		form.Add("csrf_token", validCSRFToken)
-		ts.postForm(t, "/user/login", form)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", tt.title)
+			form.Add("content", tt.content)
+			form.Add("language", tt.language)
+			form.Add("visibility", tt.visibility)
+			form.Add("expires", tt.expires)
+			form.Add("expires_value", tt.expiresValue)
+			form.Add("expires_unit", tt.expiresUnit)
+			form.Add("max_views", tt.maxViews)
+			form.Add("password", tt.password)
+			form.Add("tags", tt.tags)
+			form.Add("csrf_token", validCSRFToken)
 
-		// d) Make a GET /snippet/create request and verify
-		// receive 200 and HTML body
-		code, _, body := ts.get(t, "/snippet/create")
+			code, headers, _ := ts.postForm(t, "/snippet/create", form)
 
-		assert.Equal(t, code, http.StatusOK)
-		assert.StringContains(t, body, "<form action='/snippet/create' method='POST'>")
-	})
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+		})
+	}
 }
 
 /*
@@ -450,3 +2197,236 @@ func TestPing(t *testing.T) {
 	assert.Equal(t, string(body), "OK")
 }
 */
+
+func TestSnippetCreatePostFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	ts.login(t)
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name      string
+		filename  string
+		files     url.Values // the fields of the files after the first
+		wantCode  int
+		wantError string
+	}{
+		{
+			name:     "Single file without filename",
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Two files",
+			filename: "main.go",
+			files: url.Values{
+				"files[0].filename": {"go.mod"},
+				"files[0].content":  {"module example.com/hello"},
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name:     "Empty file skipped",
+			filename: "main.go",
+			files: url.Values{
+				"files[0].filename": {""},
+				"files[0].content":  {""},
+			},
+			wantCode: http.StatusSeeOther,
+		},
+		{
+			name: "First filename missing",
+			files: url.Values{
+				"files[0].filename": {"go.mod"},
+				"files[0].content":  {"module example.com/hello"},
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "This field cannot be blank when there are several files",
+		},
+		{
+			name:     "Filename missing",
+			filename: "main.go",
+			files: url.Values{
+				"files[0].content": {"module example.com/hello"},
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "The filename cannot be blank",
+		},
+		{
+			name:     "Duplicate filename",
+			filename: "main.go",
+			files: url.Values{
+				"files[0].filename": {"main.go"},
+				"files[0].content":  {"package main"},
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "Another file already has this filename",
+		},
+		{
+			name:     "Blank content",
+			filename: "main.go",
+			files: url.Values{
+				"files[0].filename": {"go.mod"},
+				"files[0].content":  {" "},
+			},
+			wantCode:  http.StatusUnprocessableEntity,
+			wantError: "The content cannot be blank",
+		},
+		{
+			name:     "Filename with slash",
+			filename: "cmd/main.go",
+			wantCode: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Hello")
+			form.Add("filename", tt.filename)
+			form.Add("content", "package main")
+			form.Add("visibility", "public")
+			form.Add("expires", "7")
+			form.Add("csrf_token", validCSRFToken)
+			for key, values := range tt.files {
+				form[key] = values
+			}
+
+			code, _, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			if tt.wantError != "" {
+				assert.StringContains(t, body, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, headers, _ := ts.get(t, "/snippet/fork/oldPond1")
+
+		assert.Equal(t, code, http.StatusSeeOther)
+		assert.Equal(t, headers.Get("Location"), "/user/login")
+	})
+
+	ts.login(t)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "Prefilled",
+			urlPath:  "/snippet/fork/wntrFrst",
+			wantCode: http.StatusOK,
+			wantBody: "Over the wintry forest, winds howl in rage...",
+		},
+		{
+			name:     "Links to original",
+			urlPath:  "/snippet/fork/wntrFrst",
+			wantCode: http.StatusOK,
+			wantBody: "<input type='hidden' name='forked_from' value='wntrFrst'>",
+		},
+		{
+			name:     "Copies files",
+			urlPath:  "/snippet/fork/twoFiles",
+			wantCode: http.StatusOK,
+			wantBody: "module example.com/hello",
+		},
+		{
+			name:     "Encrypted",
+			urlPath:  "/snippet/fork/encNote7",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "Burn after reading",
+			urlPath:  "/snippet/fork/burnNote",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "Locked",
+			urlPath:  "/snippet/fork/lockedUp",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "Non-existent ID",
+			urlPath:  "/snippet/fork/noSnip99",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			assert.Equal(t, code, tt.wantCode)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+			}
+		})
+	}
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	postTests := []struct {
+		name         string
+		forkedFrom   string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "Fork",
+			forkedFrom:   "wntrFrst",
+			wantCode:     http.StatusSeeOther,
+			wantLocation: "/snippet/view/newSnip2",
+		},
+		{
+			name:       "Original gone",
+			forkedFrom: "noSnip99",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "The snippet you forked is no longer available",
+		},
+		{
+			name:       "Original locked",
+			forkedFrom: "lockedUp",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantBody:   "The snippet you forked is no longer available",
+		},
+	}
+
+	for _, tt := range postTests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Over the wintry forest")
+			form.Add("content", "Over the wintry forest, winds howl in rage with no leaves to blow.")
+			form.Add("visibility", "public")
+			form.Add("expires", "7")
+			form.Add("forked_from", tt.forkedFrom)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, headers, body := ts.postForm(t, "/snippet/create", form)
+
+			assert.Equal(t, code, tt.wantCode)
+			assert.Equal(t, headers.Get("Location"), tt.wantLocation)
+
+			if tt.wantBody != "" {
+				assert.StringContains(t, body, tt.wantBody)
+				// The form is shown again without the link, to be published as a snippet of its own.
+				assert.Equal(t, strings.Contains(body, "name='forked_from'"), false)
+			}
+		})
+	}
+}
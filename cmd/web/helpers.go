@@ -2,13 +2,28 @@ package main
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
 	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"snippetbox.adpollak.net/internal/models"
+	"snippetbox.adpollak.net/internal/validator"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
 )
 
@@ -112,6 +127,40 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 	return nil
 }
 
+// Decode the JSON body of a request into dst. Bodies larger than maxBytes,
+// with unknown fields or with anything after the JSON value are rejected.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the body only held a single JSON value.
+	if dec.More() {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// Write data as a JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	js, err := json.Marshal(data)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(js, '\n'))
+}
+
 // Helper to check if a request is made by an authenticated user via
 // checking existence of authenticatedUserID value in their session data.
 func (app *application) isAuthenticated(r *http.Request) bool {
@@ -124,3 +173,461 @@ func (app *application) isAuthenticated(r *http.Request) bool {
 	}
 	return isAuthenticated
 }
+
+// Fetch the snippet named by the :id parameter of the request URL, which holds
+// its short ID. If no snippet with that ID exists, or it's private and not owned
+// by the authenticated user, a 404 Not Found response is sent, ok is false and
+// the caller should return immediately.
+// If :id holds the old integer ID of a legacy snippet, the client is redirected
+// to the same URL with the short ID instead, and ok is false too.
+func (app *application) readSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	// When parsing a request, any named parameters will be stored in the request context.
+	params := httprouter.ParamsFromContext(r.Context())
+	id := params.ByName("id")
+
+	// Use SnippetModel's Get method to retrieve the data for a specific
+	// record based on its ID. If no record is found, return a 404 Not Found response.
+	var (
+		snippet *models.Snippet
+		err     error
+	)
+	if models.IsLegacyID(id) {
+		legacyID, convErr := strconv.Atoi(id)
+		if convErr != nil || legacyID < 1 {
+			app.notFound(w)
+			return nil, false
+		}
+		snippet, err = app.snippets.GetLegacy(legacyID)
+	} else {
+		snippet, err = app.snippets.Get(id)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	// NOTE: a private snippet is reported as not found rather than forbidden,
+	// so its existence isn't revealed to other users.
+	// This is checked before redirecting from a legacy ID, so the short ID of a
+	// private snippet can't be found out from it.
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if !snippet.VisibleTo(userID) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	if snippet.ShortID != id {
+		http.Redirect(w, r, replacePathSegment(r.URL, id, snippet.ShortID), legacyRedirectStatus(r))
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Return the path and query of u, with the first path segment equal to old
+// replaced by new.
+func replacePathSegment(u *url.URL, old, new string) string {
+	segments := strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		if segment == old {
+			segments[i] = new
+			break
+		}
+	}
+
+	target := strings.Join(segments, "/")
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+	return target
+}
+
+// The status code redirecting from a legacy snippet URL. Clients change the
+// method of a 301 Moved Permanently to GET, so requests made with any other
+// method are redirected with 308 Permanent Redirect, which preserves it.
+func legacyRedirectStatus(r *http.Request) int {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return http.StatusMovedPermanently
+	}
+	return http.StatusPermanentRedirect
+}
+
+// Fetch the snippet named by the :id parameter of the request URL, for pages
+// showing its content other than the view page, such as the raw content or its
+// history. Like readSnippet, but a snippet with a view limit is only found by its
+// owner, as anyone else must reveal it on the view page, which counts the view.
+// A password-protected snippet which hasn't been unlocked gets a 403 Forbidden response.
+func (app *application) readSnippetContent(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.ViewsRemaining > 0 && !snippet.OwnedBy(userID) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	if !app.isUnlocked(r, snippet) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Whether the content of a snippet may be read by the user making the request,
+// under the same rules as readSnippetContent().
+func (app *application) canReadContent(r *http.Request, snippet *models.Snippet) bool {
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.ViewsRemaining > 0 && !snippet.OwnedBy(userID) {
+		return false
+	}
+	return app.isUnlocked(r, snippet)
+}
+
+// Return the session key recording that a password-protected snippet has been
+// unlocked during the session.
+func unlockedKey(snippet *models.Snippet) string {
+	return fmt.Sprintf("unlockedSnippet:%d", snippet.ID)
+}
+
+// Whether the content of a snippet may be shown; for a password-protected
+// snippet that's when it's owned by the authenticated user, or has been unlocked
+// during the session.
+func (app *application) isUnlocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.Protected {
+		return true
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if snippet.OwnedBy(userID) {
+		return true
+	}
+
+	return app.sessionManager.GetBool(r.Context(), unlockedKey(snippet))
+}
+
+// Fetch the snippet named by the :id parameter of the request URL, and check
+// it is owned by the authenticated user. If the snippet doesn't exist a 404 Not Found
+// response is sent, and if it belongs to someone else a 403 Forbidden response is sent.
+// In either case ok is false and the caller should return immediately.
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (*models.Snippet, bool) {
+	snippet, ok := app.readSnippet(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if !snippet.OwnedBy(userID) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Report whether the server can edit the content of a snippet. Encrypted
+// snippets can't be: the server can't read them, so can't edit them either.
+func editable(snippet *models.Snippet) bool {
+	return !snippet.Encrypted
+}
+
+// Fetch the comment named by the :id parameter of the request URL, and check it
+// was written by the authenticated user. If the comment doesn't exist a 404 Not Found
+// response is sent, and if someone else wrote it a 403 Forbidden response is sent.
+// In either case ok is false and the caller should return immediately.
+func (app *application) ownedComment(w http.ResponseWriter, r *http.Request) (*models.Comment, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil, false
+	}
+
+	comment, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	userID := app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+	if comment.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return comment, true
+}
+
+// Arrange the comments of a snippet, oldest first, into threads: return the
+// comments starting a thread, each with its replies. userID is the authenticated
+// user, or 0, and csrfToken is used by the forms of each comment.
+func threadComments(comments []*models.Comment, userID int, csrfToken string) []*commentNode {
+	threads := []*commentNode{}
+	nodes := map[int]*commentNode{}
+
+	for _, c := range comments {
+		node := &commentNode{
+			Comment:   c,
+			Own:       userID != 0 && c.UserID == userID,
+			CanReply:  userID != 0,
+			CSRFToken: csrfToken,
+		}
+		nodes[c.ID] = node
+
+		// A comment always comes after the one it replies to.
+		if parent, ok := nodes[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			threads = append(threads, node)
+		}
+	}
+
+	return threads
+}
+
+// Write the content of a snippet as a plain text response. The ETag is a hash
+// of the content, so clients can revalidate a cached copy with If-None-Match
+// and get a 304 Not Modified response if the snippet hasn't been edited since.
+func (app *application) serveSnippetContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet) {
+	sum := sha256.Sum256([]byte(snippet.Content))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", snippetCacheControl(snippet))
+
+	// ServeContent handles If-None-Match, Range and HEAD requests for us.
+	http.ServeContent(w, r, "", time.Time{}, strings.NewReader(snippet.Content))
+}
+
+// Return the Cache-Control header for the content of a snippet. Snippets can be
+// edited or deleted at any time, so caches must always revalidate. Private
+// snippets, and those with a view limit (only served to their owner), mustn't
+// be stored by shared caches at all.
+func snippetCacheControl(snippet *models.Snippet) string {
+	if snippet.Visibility == models.VisibilityPrivate || snippet.ViewsRemaining > 0 {
+		return "private, no-cache"
+	}
+	return "no-cache"
+}
+
+// Return the filename a snippet is downloaded as. That's the filename of its
+// first file if it has one. Otherwise, if the title already looks like a filename
+// with an extension (such as "main.go") it's used as is, or else the extension is
+// taken from the snippet's language.
+func snippetFilename(snippet *models.Snippet) string {
+	if snippet.Filename != "" {
+		return snippet.Filename
+	}
+
+	name := titleFilename(snippet)
+	if path.Ext(name) == "" {
+		name += languageExtension(snippet.Language)
+	}
+
+	return name
+}
+
+// Turn the title of a snippet into a filename. Characters other than letters,
+// digits, '.', '-' and '_' are replaced with '-'. Snippets without a usable
+// title are named after their short ID.
+func titleFilename(snippet *models.Snippet) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(snippet.Title))
+	name = strings.Trim(name, "-.")
+
+	if name == "" {
+		name = "snippet-" + snippet.ShortID
+	}
+
+	return name
+}
+
+// Whether name can be the filename of a file of a snippet: at most 255 characters,
+// without slashes, control characters or surrounding spaces, so it can be used
+// as is in archives and downloads.
+func validFilename(name string) bool {
+	if name == "" || name == "." || name == ".." || name != strings.TrimSpace(name) {
+		return false
+	}
+	if utf8.RuneCountInString(name) > 255 {
+		return false
+	}
+	return !strings.ContainsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\' || unicode.IsControl(r)
+	})
+}
+
+// Split the tags field of a snippet form into its tags, separated by commas or
+// spaces. Tags are lowercased, and each is kept once, in the order given.
+func parseTags(value string) []string {
+	tags := []string{}
+	fields := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, tag := range fields {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// Whether tag can be a tag, such as "sql", "k8s" or "c++".
+func validTag(tag string) bool {
+	return validator.MaxChars(tag, models.MaxTagLength) && validator.Matches(tag, validator.TagRX)
+}
+
+// Check the tags given on a snippet form, adding any errors under "tags".
+func checkTags(v *validator.Validator, tags []string) {
+	v.CheckField(len(tags) <= models.MaxTags, "tags", fmt.Sprintf("A snippet cannot have more than %d tags", models.MaxTags))
+	for _, tag := range tags {
+		v.CheckField(validTag(tag), "tags", fmt.Sprintf("Tags must be at most %d lowercase letters, digits, '+', '.' or '-', like sql or c++", models.MaxTagLength))
+	}
+}
+
+// Return the tags of the tag cloud, sized 1 to 5 by how many snippets use each
+// relative to the others.
+func tagCloud(tags []*models.Tag) []cloudTag {
+	least, most := 0, 0
+	for i, t := range tags {
+		if i == 0 || t.Count < least {
+			least = t.Count
+		}
+		most = max(most, t.Count)
+	}
+
+	cloud := make([]cloudTag, len(tags))
+	for i, t := range tags {
+		size := 1
+		if most > least {
+			size = 1 + 4*(t.Count-least)/(most-least)
+		}
+		cloud[i] = cloudTag{Tag: t, Size: size}
+	}
+	return cloud
+}
+
+// Read the after and before query string parameters of a request into a
+// cursor for keyset pagination. Invalid values are ignored.
+func readCursor(r *http.Request, limit int) models.Cursor {
+	c := models.Cursor{Limit: limit}
+
+	if after, err := strconv.Atoi(r.URL.Query().Get("after")); err == nil && after > 0 {
+		c.After = after
+	}
+	if before, err := strconv.Atoi(r.URL.Query().Get("before")); err == nil && before > 0 {
+		c.Before = before
+	}
+
+	return c
+}
+
+// Return the URL of the current request with a single pagination parameter
+// (such as after=42 or page=3) in place of any existing ones. Other query
+// parameters, like the q of a search, are kept.
+func pageURL(r *http.Request, key, value string) string {
+	query := r.URL.Query()
+	query.Del("after")
+	query.Del("before")
+	query.Del("page")
+	query.Set(key, value)
+
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// Build the links to the pages either side of a page of a keyset paginated listing.
+func cursorPagination(r *http.Request, page *models.Page) *pagination {
+	p := &pagination{}
+	if page.Prev > 0 {
+		p.PrevURL = pageURL(r, "before", strconv.Itoa(page.Prev))
+	}
+	if page.Next > 0 {
+		p.NextURL = pageURL(r, "after", strconv.Itoa(page.Next))
+	}
+	return p
+}
+
+// The shortest and longest time a snippet can be kept for, unless it never expires.
+const (
+	minExpiry = 10 * time.Minute
+	maxExpiry = 5 * 365 * 24 * time.Hour
+)
+
+// The units a custom expiry can be given in on the create form.
+var expiryUnits = map[string]time.Duration{
+	"minutes": time.Minute,
+	"hours":   time.Hour,
+	"days":    24 * time.Hour,
+	"weeks":   7 * 24 * time.Hour,
+	"years":   365 * 24 * time.Hour,
+}
+
+// Return how long a snippet is kept for, given the expires choice of the create
+// form: one of the preset number of days, "never", or "custom" for value units.
+// A duration of 0 means the snippet never expires. ok is false if the choice
+// isn't valid; checking the duration is in range is left to the caller.
+func parseExpiry(expires string, value int, unit string) (time.Duration, bool) {
+	switch expires {
+	case "never":
+		return 0, true
+	case "custom":
+		d, ok := expiryUnits[unit]
+		if !ok || value < 1 {
+			return 0, false
+		}
+		// Values too large for a time.Duration are out of range anyway, so
+		// cap them rather than let them overflow.
+		if value > int(maxExpiry/d) {
+			return maxExpiry + d, true
+		}
+		return time.Duration(value) * d, true
+	default:
+		// The presets are kept as a number of days.
+		days, err := strconv.Atoi(expires)
+		if err != nil || !validator.PermittedValue(days, 1, 7, 365) {
+			return 0, false
+		}
+		return time.Duration(days) * 24 * time.Hour, true
+	}
+}
+
+// The sizes of the IV and authentication tag of AES-GCM, in bytes.
+const (
+	gcmNonceSize = 12
+	gcmTagSize   = 16
+)
+
+// Report whether content looks like the ciphertext of an encrypted snippet:
+// the unpadded base64url encoding of a 12 byte IV followed by AES-GCM ciphertext,
+// which fits in the content column.
+// NOTE: without the key, there's no telling whether it really is ciphertext.
+func validCiphertext(content string) bool {
+	if len(content) > 65535 {
+		return false
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(content)
+	if err != nil {
+		return false
+	}
+
+	return len(raw) >= gcmNonceSize+gcmTagSize
+}
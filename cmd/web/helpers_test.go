@@ -0,0 +1,248 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adpollak.net/internal/assert"
+	"snippetbox.adpollak.net/internal/models"
+)
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		snippet  *models.Snippet
+		expected string
+	}{
+		{
+			name:     "Extension from language",
+			snippet:  &models.Snippet{ID: 1, Title: "Hello, World!", Language: "Go"},
+			expected: "Hello--World.go",
+		},
+		{
+			name:     "Extension from title",
+			snippet:  &models.Snippet{ID: 1, Title: "main.py", Language: "Go"},
+			expected: "main.py",
+		},
+		{
+			name:     "Unknown language",
+			snippet:  &models.Snippet{ID: 1, Title: "notes", Language: ""},
+			expected: "notes.txt",
+		},
+		{
+			name:     "Empty title",
+			snippet:  &models.Snippet{ID: 7, ShortID: "aB3dE5gH", Title: "???", Language: "Python"},
+			expected: "snippet-aB3dE5gH.py",
+		},
+		{
+			name:     "Filename",
+			snippet:  &models.Snippet{ID: 1, Title: "Hello, World!", Filename: "main.go", Language: "Go"},
+			expected: "main.go",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetFilename(tt.snippet), tt.expected)
+		})
+	}
+}
+
+func TestValidFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		want     bool
+	}{
+		{name: "Filename", filename: "main.go", want: true},
+		{name: "No extension", filename: "Makefile", want: true},
+		{name: "Spaces inside", filename: "read me.txt", want: true},
+		{name: "Empty", filename: "", want: false},
+		{name: "Dot", filename: ".", want: false},
+		{name: "Dot dot", filename: "..", want: false},
+		{name: "Slash", filename: "cmd/main.go", want: false},
+		{name: "Backslash", filename: `cmd\main.go`, want: false},
+		{name: "Surrounding spaces", filename: " main.go", want: false},
+		{name: "Control character", filename: "main\x00.go", want: false},
+		{name: "Too long", filename: strings.Repeat("a", 256), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, validFilename(tt.filename), tt.want)
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{name: "Empty", value: "  ", want: []string{}},
+		{name: "Spaces", value: "sql regex", want: []string{"sql", "regex"}},
+		{name: "Commas", value: "sql,regex, k8s", want: []string{"sql", "regex", "k8s"}},
+		{name: "Lowercased", value: "SQL Regex", want: []string{"sql", "regex"}},
+		{name: "Duplicates", value: "sql regex SQL", want: []string{"sql", "regex"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, strings.Join(parseTags(tt.value), " "), strings.Join(tt.want, " "))
+		})
+	}
+}
+
+func TestValidTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want bool
+	}{
+		{name: "Letters", tag: "sql", want: true},
+		{name: "Digits", tag: "k8s", want: true},
+		{name: "Symbols", tag: "c++", want: true},
+		{name: "Dots and dashes", tag: "node.js-v20", want: true},
+		{name: "Empty", tag: "", want: false},
+		{name: "Uppercase", tag: "SQL", want: false},
+		{name: "Leading symbol", tag: "-sql", want: false},
+		{name: "Hash", tag: "c#", want: false},
+		{name: "Too long", tag: strings.Repeat("a", 33), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, validTag(tt.tag), tt.want)
+		})
+	}
+}
+
+func TestTagCloud(t *testing.T) {
+	cloud := tagCloud([]*models.Tag{
+		{Name: "go", Count: 9},
+		{Name: "k8s", Count: 5},
+		{Name: "sql", Count: 1},
+	})
+
+	assert.Equal(t, len(cloud), 3)
+	assert.Equal(t, cloud[0].Size, 5)
+	assert.Equal(t, cloud[1].Size, 3)
+	assert.Equal(t, cloud[2].Size, 1)
+
+	// When every tag is used as often, they're all the smallest size.
+	cloud = tagCloud([]*models.Tag{{Name: "go", Count: 2}, {Name: "sql", Count: 2}})
+	assert.Equal(t, cloud[0].Size, 1)
+	assert.Equal(t, cloud[1].Size, 1)
+}
+
+func TestThreadComments(t *testing.T) {
+	comments := []*models.Comment{
+		{ID: 1, UserID: 1},
+		{ID: 2, UserID: 2, ParentID: 1},
+		{ID: 3, UserID: 2},
+		{ID: 4, UserID: 1, ParentID: 2},
+		{ID: 5, UserID: 2, ParentID: 1},
+	}
+
+	threads := threadComments(comments, 1, "token")
+
+	assert.Equal(t, len(threads), 2)
+	assert.Equal(t, threads[0].ID, 1)
+	assert.Equal(t, threads[0].Own, true)
+	assert.Equal(t, len(threads[0].Replies), 2)
+	assert.Equal(t, threads[0].Replies[0].ID, 2)
+	assert.Equal(t, threads[0].Replies[0].Own, false)
+	assert.Equal(t, threads[0].Replies[0].Replies[0].ID, 4)
+	assert.Equal(t, threads[0].Replies[1].ID, 5)
+	assert.Equal(t, threads[1].ID, 3)
+	assert.Equal(t, len(threads[1].Replies), 0)
+
+	// Readers who aren't logged in can't reply to or change any comment.
+	threads = threadComments(comments, 0, "token")
+	assert.Equal(t, threads[0].Own, false)
+	assert.Equal(t, threads[0].CanReply, false)
+}
+
+func TestCursorPagination(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		page        *models.Page
+		wantPrevURL string
+		wantNextURL string
+	}{
+		{
+			name:        "First page",
+			url:         "/snippets",
+			page:        &models.Page{Next: 21},
+			wantNextURL: "/snippets?after=21",
+		},
+		{
+			name:        "Middle page",
+			url:         "/snippets?after=21",
+			page:        &models.Page{Prev: 20, Next: 1},
+			wantPrevURL: "/snippets?before=20",
+			wantNextURL: "/snippets?after=1",
+		},
+		{
+			name:        "Keeps other parameters",
+			url:         "/search?q=pond&before=5",
+			page:        &models.Page{Next: 6},
+			wantNextURL: "/search?after=6&q=pond",
+		},
+		{
+			name: "Only page",
+			url:  "/snippets",
+			page: &models.Page{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.url, nil)
+
+			p := cursorPagination(r, tt.page)
+
+			assert.Equal(t, p.PrevURL, tt.wantPrevURL)
+			assert.Equal(t, p.NextURL, tt.wantNextURL)
+		})
+	}
+}
+
+func TestReadCursor(t *testing.T) {
+	r := httptest.NewRequest("GET", "/snippets?after=42&before=foo", nil)
+
+	assert.Equal(t, readCursor(r, 20), models.Cursor{After: 42, Limit: 20})
+}
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		expires string
+		value   int
+		unit    string
+		want    time.Duration
+		wantOK  bool
+	}{
+		{name: "Preset", expires: "7", want: 7 * 24 * time.Hour, wantOK: true},
+		{name: "Unknown preset", expires: "2", wantOK: false},
+		{name: "Never", expires: "never", want: 0, wantOK: true},
+		{name: "Custom", expires: "custom", value: 90, unit: "minutes", want: 90 * time.Minute, wantOK: true},
+		{name: "Custom zero", expires: "custom", value: 0, unit: "hours", wantOK: false},
+		{name: "Unknown unit", expires: "custom", value: 3, unit: "fortnights", wantOK: false},
+		{name: "Overflow", expires: "custom", value: 1 << 40, unit: "years", want: maxExpiry + 365*24*time.Hour, wantOK: true},
+		{name: "Empty", expires: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseExpiry(tt.expires, tt.value, tt.unit)
+
+			assert.Equal(t, ok, tt.wantOK)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// The languages offered by the language picker on the snippet forms. Each is
+// the name of a chroma lexer. An empty language means auto-detect it.
+var languages = []string{
+	"Bash", "C", "C#", "C++", "CSS", "Diff", "Docker", "Go", "GraphQL", "HTML",
+	"INI", "Java", "JavaScript", "JSON", "Kotlin", "Lua", "Makefile", "Markdown",
+	"MySQL", "Perl", "PHP", "PowerShell", "Python", "Ruby", "Rust", "Scala",
+	"SQL", "Swift", "TOML", "TypeScript", "YAML", "plaintext",
+}
+
+// Formats tokens as HTML using CSS classes (see ui/static/css/chroma.css)
+// rather than inline styles, which the Content-Security-Policy set by
+// secureHeaders doesn't allow.
+var highlighter = html.New(html.WithClasses(true), html.TabWidth(4))
+
+// Like highlighter, but formats the tokens of a single line without wrapping
+// them in <pre> or line elements, which highlightLines() adds itself.
+var lineHighlighter = html.New(html.WithClasses(true), html.TabWidth(4), html.PreventSurroundingPre(true))
+
+// The chroma style ui/static/css/chroma.css was generated from.
+var highlightStyle = styles.Get("github")
+
+// Guess the language of some content from its text. Returns "plaintext" if
+// no lexer recognises it.
+func detectLanguage(content string) string {
+	lexer := lexers.Analyse(content)
+	if lexer == nil {
+		return "plaintext"
+	}
+	return lexer.Config().Name
+}
+
+// Guess the language of a file from its filename, such as Go for "main.go",
+// falling back to guessing it from its content.
+func detectFileLanguage(filename, content string) string {
+	if filename != "" {
+		if lexer := lexers.Match(filename); lexer != nil {
+			return lexer.Config().Name
+		}
+	}
+	return detectLanguage(content)
+}
+
+// Return the lexer for a language, auto-detecting it from the content if the
+// language is empty or unknown.
+func lexerFor(content, language string) chroma.Lexer {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	// Merge runs of identical token types, which keeps the output HTML small.
+	return chroma.Coalesce(lexer)
+}
+
+// NOTE: custom function used in our Go template.
+// Render content as syntax highlighted HTML, wrapped in a <pre> element.
+// The output of the formatter is escaped, so it's safe to return as template.HTML.
+func highlight(content, language string) (template.HTML, error) {
+	iterator, err := lexerFor(content, language).Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = highlighter.Format(&buf, highlightStyle, iterator)
+	if err != nil {
+		return "", err
+	}
+
+	return template.HTML(buf.String()), nil
+}
+
+// Return the usual file extension of a language, including the leading dot,
+// taken from the filename patterns of its lexer (such as "*.go" for Go).
+// Returns ".txt" if the language is unknown or has no extension.
+func languageExtension(language string) string {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		return ".txt"
+	}
+
+	for _, pattern := range lexer.Config().Filenames {
+		ext := strings.TrimPrefix(pattern, "*")
+		// Skip patterns like "Makefile" or "*.[ch]" that aren't a plain extension.
+		if strings.HasPrefix(ext, ".") && !strings.ContainsAny(ext, "*?[") {
+			return ext
+		}
+	}
+
+	return ".txt"
+}
+
+// A range of line numbers, such as the 12-20 in "#L12-L20". The zero value
+// is an empty range.
+type lineRange struct {
+	Start int
+	End   int
+}
+
+// Whether the line number n falls inside the range.
+func (lr lineRange) Contains(n int) bool {
+	return lr.Start > 0 && lr.Start <= n && n <= lr.End
+}
+
+// Parse a range of lines written as "12", "12-20", "L12" or "L12-L20".
+// Returns the empty range if the value isn't a valid range.
+func parseLineRange(value string) lineRange {
+	start, end, found := strings.Cut(value, "-")
+	if !found {
+		end = start
+	}
+
+	first, err := strconv.Atoi(strings.TrimPrefix(start, "L"))
+	if err != nil || first < 1 {
+		return lineRange{}
+	}
+	last, err := strconv.Atoi(strings.TrimPrefix(end, "L"))
+	if err != nil || last < 1 {
+		return lineRange{}
+	}
+
+	// Accept ranges written backwards, like "L20-L12".
+	if first > last {
+		first, last = last, first
+	}
+
+	return lineRange{Start: first, End: last}
+}
+
+// A single highlighted line of a snippet, as rendered on the view page.
+type codeLine struct {
+	Number      int // numbered from 1
+	HTML        template.HTML
+	Highlighted bool           // whether the line is in the range selected by the reader
+	Comments    []*commentNode // the comment threads attached to the line
+}
+
+// Return the number of lines of content, numbered as by highlightLines(). A
+// final newline doesn't start another line.
+func lineCount(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// Render content as syntax highlighted HTML, one entry per line, so that the
+// template can number and anchor each line. Lines in the selected range are
+// marked as highlighted.
+func highlightLines(content, language string, selected lineRange) ([]codeLine, error) {
+	iterator, err := lexerFor(content, language).Tokenise(nil, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []codeLine
+	for i, tokens := range chroma.SplitTokensIntoLines(iterator.Tokens()) {
+		// NOTE: the tokens of each line end in its newline, so copying the code
+		// from the page keeps the line breaks.
+		var buf bytes.Buffer
+		err = lineHighlighter.Format(&buf, highlightStyle, chroma.Literator(tokens...))
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, codeLine{
+			Number:      i + 1,
+			HTML:        template.HTML(buf.String()),
+			Highlighted: selected.Contains(i + 1),
+		})
+	}
+
+	return lines, nil
+}
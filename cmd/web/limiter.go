@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Counts attempts, such as passwords given to unlock a snippet, so they can't be
+// guessed by brute force. Once a key has had max attempts, it's refused until
+// window has passed since its first one.
+// NOTE: attempts are only counted in memory, so are reset when the server restarts,
+// and aren't shared between several instances of it.
+type attemptLimiter struct {
+	mu        sync.Mutex
+	max       int
+	window    time.Duration
+	attempts  map[string]*attempts
+	lastSweep time.Time
+	now       func() time.Time // replaced in tests
+}
+
+// The attempts of a single key.
+type attempts struct {
+	count int
+	first time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]*attempts),
+		now:      time.Now,
+	}
+}
+
+// Report whether another attempt is allowed for key, and if so count it.
+// NOTE: the attempt is counted here, under the same lock as the check, rather
+// than once it has failed. Otherwise concurrent requests would all be allowed
+// while the slow password checks of the previous ones are still running.
+func (l *attemptLimiter) Allow(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// Every so often, forget about the keys whose window has passed so the map
+	// doesn't keep growing.
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, a := range l.attempts {
+			if now.Sub(a.first) >= l.window {
+				delete(l.attempts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	a, ok := l.attempts[key]
+	if !ok || now.Sub(a.first) >= l.window {
+		a = &attempts{first: now}
+		l.attempts[key] = a
+	}
+	if a.count >= l.max {
+		return false
+	}
+	a.count++
+	return true
+}
+
+// Take back the attempt last counted for key, once it succeeded, so that only
+// failed attempts count towards the limit.
+func (l *attemptLimiter) Refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if a, ok := l.attempts[key]; ok && a.count > 0 {
+		a.count--
+	}
+}
+
+// Forget every attempt for key, after a successful one.
+func (l *attemptLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// Return the IP address of the client making a request, to tell clients apart.
+// NOTE: behind a reverse proxy this is the address of the proxy.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+func TestAttemptLimiter(t *testing.T) {
+	now := time.Date(2024, 3, 17, 10, 15, 0, 0, time.UTC)
+
+	l := newAttemptLimiter(3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for range 3 {
+		assert.Equal(t, l.Allow("a"), true)
+	}
+
+	// Only the key which had too many attempts is refused.
+	assert.Equal(t, l.Allow("a"), false)
+	assert.Equal(t, l.Allow("b"), true)
+
+	// Once the window has passed, attempts are allowed again.
+	now = now.Add(time.Minute)
+	assert.Equal(t, l.Allow("a"), true)
+
+	// A successful attempt forgets about the others.
+	l.Allow("b")
+	l.Reset("b")
+	l.Allow("b")
+	l.Allow("b")
+	assert.Equal(t, l.Allow("b"), true)
+
+	// Or only about itself, once refunded.
+	l.Allow("c")
+	l.Allow("c")
+	l.Allow("c")
+	l.Refund("c")
+	assert.Equal(t, l.Allow("c"), true)
+	assert.Equal(t, l.Allow("c"), false)
+}
+
+func TestAttemptLimiterConcurrent(t *testing.T) {
+	l := newAttemptLimiter(5, time.Minute)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	// Attempts made at the same time are counted before any of them is done,
+	// so no more than the limit get through.
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if l.Allow("a") {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, allowed, 5)
+}
@@ -3,11 +3,15 @@ package main
 import (
 	"crypto/tls"
 	"database/sql"
+	"errors"
 	"flag"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"snippetbox.adpollak.net/internal/models"
@@ -21,14 +25,22 @@ import (
 // This type holds application-wide dependencies for our webapp.
 // Updated snippets and users to use their interface types.
 type application struct {
-	debug          bool
-	errorLog       *log.Logger
-	infoLog        *log.Logger
-	snippets       models.SnippetModelInterface
-	users          models.UserModelInterface
-	templateCache  map[string]*template.Template // make avail cache to our handlers
-	formDecoder    *form.Decoder
-	sessionManager *scs.SessionManager
+	debug                bool
+	baseURL              string // the public URL of the site without a trailing slash, or empty if it isn't known
+	errorLog             *log.Logger
+	infoLog              *log.Logger
+	snippets             models.SnippetModelInterface
+	users                models.UserModelInterface
+	revisions            models.RevisionModelInterface
+	tags                 models.TagModelInterface
+	stars                models.StarModelInterface
+	comments             models.CommentModelInterface
+	tokens               models.TokenModelInterface
+	templateCache        map[string]*template.Template // make avail cache to our handlers
+	formDecoder          *form.Decoder
+	sessionManager       *scs.SessionManager
+	unlockLimiter        *attemptLimiter // attempts to unlock password-protected snippets, by snippet and client
+	snippetUnlockLimiter *attemptLimiter // attempts to unlock password-protected snippets, by snippet
 }
 
 // Wraps sql.Open() and returns a sql.DB connection pool for
@@ -47,20 +59,125 @@ func openDB(dsn string) (*sql.DB, error) {
 	return db, nil
 }
 
-func main() {
+// Check the -base-url flag is an absolute http or https URL, returning it
+// without a trailing slash so paths can be appended to it.
+func parseBaseURL(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("-base-url %q isn't an absolute http or https URL", s)
+	}
+
+	return strings.TrimSuffix(s, "/"), nil
+}
+
+// The environment variable the master keys are read from, when no file is given with -master-keys.
+const masterKeysEnv = "SNIPPETBOX_MASTER_KEYS"
+
+// Load the master keys used to encrypt the content of snippets at rest, from
+// the file at path, or if path is empty, from the environment. Returns a nil
+// keyring if neither holds any keys, in which case content is stored in the clear.
+// See models.ParseKeyring() for the format of the keys.
+func loadKeyring(path string) (*models.Keyring, error) {
+	text := os.Getenv(masterKeysEnv)
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(b)
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	return models.ParseKeyring(text)
+}
+
+// The configuration of the application, read from the command-line flags.
+type config struct {
+	addr       string
+	dsn        string
+	debug      bool
+	baseURL    string // without a trailing slash, or empty if it isn't known
+	masterKeys string
+	rekey      bool
+}
+
+// Parse the command-line flags in args, the arguments without the program's
+// name. The flag set reports any error itself, along with the usage.
+func parseFlags(args []string) (*config, error) {
+	cfg := &config{}
+	flags := flag.NewFlagSet("web", flag.ContinueOnError)
+
 	// Define a cli arg named `addr`, w/ default value of :4000.
 	// Additionally define some help text to explain flag controls
-	addr := flag.String("addr", ":4000", "HTTP network address")
+	flags.StringVar(&cfg.addr, "addr", ":4000", "HTTP network address")
 	// Define a new cli flag for the MySQL DSN string
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	flags.StringVar(&cfg.dsn, "dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
 	// New cli flag for debug mode
-	debug := flag.Bool("debug", false, "Enable debug mode")
+	flags.BoolVar(&cfg.debug, "debug", false, "Enable debug mode")
+	// The public URL of the site, for the links the paste endpoint answers with.
+	flags.StringVar(&cfg.baseURL, "base-url", "", "Public URL of the site, such as https://snippetbox.example.com (default: pastes are answered with a path only)")
+	// Master keys for encrypting snippets at rest. To rotate them, add a new key at
+	// the top of the file and restart every instance of the application with it.
+	// Only then run with -rekey, after which the old key can be removed.
+	// With master keys, search only matches the titles of snippets, not their content.
+	flags.StringVar(&cfg.masterKeys, "master-keys", "", "File holding the master keys snippets are encrypted at rest with (default $"+masterKeysEnv+")")
+	flags.BoolVar(&cfg.rekey, "rekey", false, "Re-encrypt all snippets with the current master key, then exit")
+
+	// Parse CLI flags.
+	// NOTE: You MUST call this BEFORE you use the flag values, otherwise they
+	// will ALWAYS hold their default values.
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
 
-	// Parse CLI flag.
-	// This reads in the CLI flag value and assigns it to addr.
-	// NOTE: You MUST call this BEFORE you use the addr value, otherwise it
-	// will ALWAYS use the default value.
-	flag.Parse()
+	var err error
+	cfg.baseURL, err = parseBaseURL(cfg.baseURL)
+	if err != nil {
+		fmt.Fprintln(flags.Output(), err)
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Return an application configured by cfg, with the dependencies which don't
+// depend on the database. main() adds the models and session manager backed by
+// MySQL, and the tests their mocks and an in-memory session store.
+func newApplication(cfg *config, infoLog, errorLog *log.Logger) (*application, error) {
+	// Initialize new template cache
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		return nil, err
+	}
+
+	return &application{
+		debug:                cfg.debug,
+		baseURL:              cfg.baseURL,
+		errorLog:             errorLog,
+		infoLog:              infoLog,
+		templateCache:        templateCache,
+		formDecoder:          form.NewDecoder(),
+		unlockLimiter:        newAttemptLimiter(maxUnlockAttempts, unlockWindow),
+		snippetUnlockLimiter: newAttemptLimiter(maxSnippetUnlockAttempts, unlockWindow),
+	}, nil
+}
+
+func main() {
+	cfg, err := parseFlags(os.Args[1:])
+	if err != nil {
+		// The flag set has already printed the error and the usage.
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
 
 	// Create a new logger using log.New() for writing information messages.
 	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
@@ -68,38 +185,56 @@ func main() {
 	// log.Lshortfile flag includes relevant file name and line number.
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	db, err := openDB(*dsn)
+	db, err := openDB(cfg.dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
 	defer db.Close() // NOTE:
 
-	// Initialize new template cache
-	templateCache, err := newTemplateCache()
+	keys, err := loadKeyring(cfg.masterKeys)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
+	if keys == nil {
+		infoLog.Printf("No master keys given, snippets are stored unencrypted")
+	} else {
+		// NOTE: the FULLTEXT index can't see encrypted content, see models.SnippetModel.SearchesContent().
+		infoLog.Printf("Snippets are encrypted at rest, so search only matches their titles")
+	}
 
-	formDecoder := form.NewDecoder()
+	// Rather than starting the server, bring the encryption of every snippet up to
+	// date with the current master key.
+	if cfg.rekey {
+		if keys == nil {
+			errorLog.Fatal("-rekey needs master keys, see -master-keys")
+		}
+		n, err := models.Rekey(db, keys)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		infoLog.Printf("Re-encrypted %d rows with master key %q", n, keys.Current())
+		return
+	}
+
+	app, err := newApplication(cfg, infoLog, errorLog)
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	// Add the models to the application dependencies, backed by MySQL.
+	app.snippets = &models.SnippetModel{DB: db, Keys: keys}
+	app.users = &models.UserModel{DB: db}
+	app.revisions = &models.RevisionModel{DB: db, Keys: keys}
+	app.tags = &models.TagModel{DB: db}
+	app.stars = &models.StarModel{DB: db, Keys: keys}
+	app.comments = &models.CommentModel{DB: db}
+	app.tokens = &models.TokenModel{DB: db}
 
 	// NOTE: Initialize a new sessionManager. Configured to use
 	// our MySQL db as the session store, and set a lifetime of 12 hours.
-	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
-	sessionManager.Lifetime = 12 * time.Hour
-
-	// Initialize a models.SnippetModel instance and add it to the application
-	// dependencies.
-	app := &application{
-		debug:          *debug,
-		errorLog:       errorLog,
-		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-	}
+	app.sessionManager = scs.New()
+	app.sessionManager.Store = mysqlstore.New(db)
+	app.sessionManager.Lifetime = 12 * time.Hour
 
 	// Initialize a tlsConfig struct to hold non-default TLS settings we want the server to use.
 	// Only changing curve preference values, so only elliptic curves with assemply impls
@@ -112,7 +247,7 @@ func main() {
 	// We initialize a new http.Server struct containing the config settings for
 	// the server as opposed to using the ListenAndServe shortcut to use our custom error.
 	srv := &http.Server{
-		Addr:      *addr,
+		Addr:      cfg.addr,
 		ErrorLog:  errorLog,
 		Handler:   app.routes(), // updated to call app.routes() to get the servemux containing our routes.
 		TLSConfig: tlsConfig,
@@ -122,7 +257,7 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 	}
 
-	infoLog.Printf("Starting server on %s\n", *addr)
+	infoLog.Printf("Starting server on %s\n", cfg.addr)
 	// err := http.ListenAndServe(*addr, mux)
 	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
 	errorLog.Fatal(err)
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+func TestParseFlagsBaseURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{name: "Unset", baseURL: "", want: ""},
+		{name: "HTTPS", baseURL: "https://snippetbox.example.com", want: "https://snippetbox.example.com"},
+		{name: "Trailing slash", baseURL: "http://localhost:4000/", want: "http://localhost:4000"},
+		{name: "No scheme", baseURL: "snippetbox.example.com", wantErr: true},
+		{name: "Other scheme", baseURL: "ftp://snippetbox.example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseFlags([]string{"-base-url", tt.baseURL})
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+			assert.NilError(t, err)
+
+			// The flag makes it to the application main() builds.
+			app, err := newApplication(cfg, nil, nil)
+			assert.NilError(t, err)
+			assert.Equal(t, app.baseURL, tt.want)
+		})
+	}
+}
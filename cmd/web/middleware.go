@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/justinas/nosurf"
+	"snippetbox.adpollak.net/internal/models"
 )
 
 // This is a middleware function.
@@ -114,6 +117,83 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 	})
 }
 
+// Set the WWW-Authenticate header of a 401 Unauthorized response from the API.
+func setAPIChallenge(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox"`)
+}
+
+// Middleware authenticating requests to the API with a personal API token,
+// sent as "Authorization: Bearer <token>". The API has no sessions, so like
+// authenticate() does with the session, it puts the token's user into the
+// request context for apiUserID(), along with the token's scope for apiScope().
+// Requests without an Authorization header are anonymous; ones with an invalid
+// or revoked token get a 401 Unauthorized response.
+// NOTE: API tokens are the only credentials accepted. Passwords, such as with
+// HTTP Basic authentication, are refused: every one would cost a bcrypt check
+// outside of the login form, and would be granted every scope.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		scheme, token, ok := strings.Cut(authorization, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			setAPIChallenge(w)
+			app.apiError(w, http.StatusUnauthorized, "Authenticate with an API token, sent as Authorization: Bearer <token>")
+			return
+		}
+
+		t, err := app.tokens.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				setAPIChallenge(w)
+				app.apiError(w, http.StatusUnauthorized, "The API token is invalid or has been revoked")
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), apiUserIDContextKey, t.UserID)
+		ctx = context.WithValue(ctx, apiScopeContextKey, t.Scope)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Middleware sending a 401 Unauthorized response to anonymous requests to the
+// API, for the routes which are about the user or change snippets.
+func (app *application) requireAPIUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiUserID(r) == 0 {
+			setAPIChallenge(w)
+			app.apiError(w, http.StatusUnauthorized, "You must authenticate to use this endpoint")
+			return
+		}
+
+		// Responses for a user aren't to be cached, like pages behind requireAuthentication.
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Middleware sending a 403 Forbidden response to requests to the API
+// authenticated with a read-only token, for the routes which change snippets.
+// NOTE: comes after requireAPIUser, so anonymous requests never reach it.
+func (app *application) requireAPIWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiScope(r) != models.ScopeWrite {
+			app.apiError(w, http.StatusForbidden, "This endpoint needs a token with the write scope")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Middleware that uses a customized CSRF cookie with
 // Secure, Path, and HttpOnly attributes set.
 func noSurf(next http.Handler) http.Handler {
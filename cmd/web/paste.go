@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"snippetbox.adpollak.net/internal/models"
+	"snippetbox.adpollak.net/internal/validator"
+)
+
+// NOTE: the paste endpoint, for piping the output of a command into a snippet
+// from a terminal, like sprunge or termbin:
+//
+//	some-command | curl --data-binary @- https://snippetbox/paste
+//
+// It's answered in plain text rather than HTML or JSON, so the URL of the new
+// snippet, under the -base-url the application is run with, can be used as it
+// is. Like the API it has no sessions or CSRF protection, and is authenticated
+// with an API token or anonymous.
+
+// The largest paste accepted, in bytes: as much as a snippet's content column holds.
+const maxPasteBytes = 65535
+
+// Expiries written like 10m, 12h, 3d, 2w or 1y, as a shorthand for a custom expiry.
+var pasteExpiryRX = regexp.MustCompile(`^(\d+)([mhdwy])$`)
+
+// The expiry unit of the create form each shorthand suffix stands for.
+var pasteExpiryUnits = map[string]string{
+	"m": "minutes",
+	"h": "hours",
+	"d": "days",
+	"w": "weeks",
+	"y": "years",
+}
+
+// Creates a snippet from the raw request body, and answers with its URL in plain
+// text. The query string can set the title, filename, language, tags,
+// visibility and expires of the snippet, the same as the create form; expires
+// also takes a shorthand like 12h. Pastes are unlisted and kept for a week
+// unless told otherwise.
+func (app *application) pastePost(w http.ResponseWriter, r *http.Request) {
+	userID := apiUserID(r)
+
+	// Anonymous pastes are allowed, but a token must be able to write.
+	if userID != 0 && apiScope(r) != models.ScopeWrite {
+		http.Error(w, "Pasting needs a token with the write scope", http.StatusForbidden)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasteBytes)
+
+	content, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, fmt.Sprintf("Pastes cannot be more than %d bytes", maxPasteBytes), http.StatusRequestEntityTooLarge)
+		} else {
+			app.clientError(w, http.StatusBadRequest)
+		}
+		return
+	}
+
+	query := r.URL.Query()
+
+	form := snippetCreateForm{
+		Title:      query.Get("title"),
+		Filename:   query.Get("filename"),
+		Content:    string(content),
+		Language:   query.Get("language"),
+		Tags:       query.Get("tags"),
+		Visibility: query.Get("visibility"),
+		Expires:    query.Get("expires"),
+	}
+
+	// Typing go rather than Go is allowed here, to spare shell users the capitals.
+	for _, language := range languages {
+		if strings.EqualFold(form.Language, language) {
+			form.Language = language
+		}
+	}
+
+	if form.Title == "" {
+		form.Title = "Paste"
+	}
+	if form.Visibility == "" {
+		form.Visibility = models.VisibilityUnlisted
+	}
+	if form.Expires == "" {
+		form.Expires = "7"
+	}
+	if m := pasteExpiryRX.FindStringSubmatch(form.Expires); m != nil {
+		// Values too large for an int are left 0, which is out of range.
+		form.Expires = "custom"
+		form.ExpiresValue, _ = strconv.Atoi(m[1])
+		form.ExpiresUnit = pasteExpiryUnits[m[2]]
+	}
+
+	expires, tags := form.validate()
+	form.CheckField(utf8.ValidString(form.Content), "content", "This field must be UTF-8 text")
+	// Nobody could ever read a private snippet without an owner.
+	form.CheckField(userID != 0 || form.Visibility != models.VisibilityPrivate, "visibility", "Anonymous pastes cannot be private")
+
+	if !form.Valid() {
+		http.Error(w, pasteErrors(form.Validator), http.StatusUnprocessableEntity)
+		return
+	}
+
+	shortID, err := app.snippets.Insert(form.snippet(userID, tags), expires, "")
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// NOTE: the full URL is built from the configured -base-url rather than the
+	// request's Host header, which the client controls, and whose scheme can't be
+	// told apart from r.TLS behind a proxy. Without one, only the path is given.
+	path := "/snippet/view/" + shortID
+
+	w.Header().Set("Location", path)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintln(w, app.baseURL+path)
+}
+
+// Return the errors of a failed validation as plain text, a line for each,
+// with the errors of fields named after them in alphabetical order.
+func pasteErrors(v validator.Validator) string {
+	var lines []string
+
+	lines = append(lines, v.NonFieldErrors...)
+
+	fields := make([]string, 0, len(v.FieldErrors))
+	for field := range v.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		lines = append(lines, field+": "+v.FieldErrors[field])
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
@@ -12,12 +13,47 @@ import (
 // NOTE: we changed the return type from ServeMux to Handler
 // since we wanted to wrap our middleware around the ServeMux.
 func (app *application) routes() http.Handler {
+	// NOTE: logRequest ↔ secureHeaders ↔ servemux ↔ handler
+	// return app.recoverPanic(app.logRequest(secureHeaders(mux)))
+	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	// Return the standard middleware chain followed by the servemux
+	return standard.Then(app.router())
+}
+
+// A router which also keeps a list of the routes registered with it.
+// NOTE: httprouter can't list its routes, and TestOpenAPI needs them to check
+// every /api route is described by the OpenAPI document.
+type recordingRouter struct {
+	*httprouter.Router
+	routes []route
+}
+
+// Registers and records a route.
+func (rr *recordingRouter) Handler(method, path string, handler http.Handler) {
+	rr.routes = append(rr.routes, route{method, path, handler})
+	rr.Router.Handler(method, path, handler)
+}
+
+// Registers and records a route, like Handler.
+// NOTE: httprouter's own HandlerFunc would skip our Handler.
+func (rr *recordingRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	rr.Handler(method, path, handler)
+}
+
+// Returns the router with all of our application routes, without the
+// standard middleware chain.
+func (app *application) router() *recordingRouter {
 	// Initialize the router
-	router := httprouter.New()
+	router := &recordingRouter{Router: httprouter.New()}
 
 	// Create a handler function that wraps our notFound helper, and then assigns it as the custom
 	// handler for the 404 Not Found responses.
 	router.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Clients of the API expect a JSON error, even for paths it doesn't have.
+		if strings.HasPrefix(r.URL.Path, "/api/") {
+			app.apiError(w, http.StatusNotFound, "Not found")
+			return
+		}
 		app.notFound(w)
 	})
 
@@ -44,7 +80,18 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
 	// About route
 	router.Handler(http.MethodGet, "/about", dynamic.ThenFunc(app.about))
+	router.Handler(http.MethodGet, "/search", dynamic.ThenFunc(app.search))
+	router.Handler(http.MethodGet, "/snippets", dynamic.ThenFunc(app.snippetArchive))
+	router.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(app.tagView))
 	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodPost, "/snippet/view/:id", dynamic.ThenFunc(app.snippetRevealPost))
+	router.Handler(http.MethodPost, "/snippet/unlock/:id", dynamic.ThenFunc(app.snippetUnlockPost))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:id/history/:version", dynamic.ThenFunc(app.snippetRevision))
+	router.Handler(http.MethodGet, "/snippet/view/:id/diff", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodGet, "/snippet/raw/:id", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:id", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/download/:id/archive", dynamic.ThenFunc(app.snippetDownloadArchive))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
@@ -56,14 +103,72 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	// Encrypted snippets are sent as JSON by the browser, once it has encrypted them.
+	router.Handler(http.MethodGet, "/snippet/create/encrypted", protected.ThenFunc(app.snippetCreateEncrypted))
+	router.Handler(http.MethodPost, "/snippet/create/encrypted", protected.ThenFunc(app.snippetCreateEncryptedPost))
+	// A fork is published with the create form, prefilled from the original.
+	router.Handler(http.MethodGet, "/snippet/fork/:id", protected.ThenFunc(app.snippetFork))
+	// Editing and deleting check the snippet is owned by the authenticated user.
+	router.Handler(http.MethodGet, "/snippet/edit/:id", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:id", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:id", protected.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodPost, "/snippet/star/:id", protected.ThenFunc(app.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:id", protected.ThenFunc(app.snippetUnstarPost))
+	// Editing and deleting a comment check it was written by the authenticated user.
+	router.Handler(http.MethodPost, "/snippet/comment/:id", protected.ThenFunc(app.snippetCommentPost))
+	router.Handler(http.MethodGet, "/comment/edit/:id", protected.ThenFunc(app.commentEdit))
+	router.Handler(http.MethodPost, "/comment/edit/:id", protected.ThenFunc(app.commentEditPost))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protected.ThenFunc(app.commentDeletePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
 	router.Handler(http.MethodGet, "/account/view", protected.ThenFunc(app.accountView))
+	router.Handler(http.MethodGet, "/account/favorites", protected.ThenFunc(app.accountFavorites))
 	router.Handler(http.MethodGet, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdate))
 	router.Handler(http.MethodPost, "/account/password/update", protected.ThenFunc(app.accountPasswordUpdatePost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.accountTokenRevokePost))
 
-	// NOTE: logRequest ↔ secureHeaders ↔ servemux ↔ handler
-	// return app.recoverPanic(app.logRequest(secureHeaders(mux)))
-	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
-	// Return the standard middleware chain followed by the servemux
-	return standard.Then(router)
+	// The JSON API's routes are listed by apiRoutes().
+	for _, rt := range app.apiRoutes() {
+		router.Handler(rt.method, rt.path, rt.handler)
+	}
+
+	// NOTE: pastes are sent by curl rather than a browser, so like the API they
+	// skip the session and nosurf, and are authenticated with an API token.
+	paste := alice.New(app.authenticateToken)
+	router.Handler(http.MethodPost, "/paste", paste.ThenFunc(app.pastePost))
+
+	return router
+}
+
+// A route registered with the router.
+type route struct {
+	method  string
+	path    string
+	handler http.Handler
+}
+
+// Returns the routes of the JSON API, which are all under /api.
+// NOTE: every /api route must be described by the OpenAPI document in
+// ui/api/openapi.json; TestOpenAPI checks they are.
+func (app *application) apiRoutes() []route {
+	// NOTE: the JSON API uses its own "api" middleware chain, without sessions or
+	// CSRF protection, as its clients authenticate on every request instead,
+	// with an API token.
+	api := alice.New(app.authenticateToken)
+	// The "apiUser" chain is for any authenticated user, and the "apiProtected"
+	// chain only for those who can write.
+	apiUser := api.Append(app.requireAPIUser)
+	apiProtected := apiUser.Append(app.requireAPIWrite)
+
+	return []route{
+		{http.MethodGet, "/api/openapi.json", http.HandlerFunc(app.apiSpec)},
+		{http.MethodGet, "/api/v1/search", api.ThenFunc(app.apiSearch)},
+		{http.MethodGet, "/api/v1/user/snippets", apiUser.ThenFunc(app.apiUserSnippets)},
+		{http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList)},
+		{http.MethodPost, "/api/v1/snippets", apiProtected.ThenFunc(app.apiSnippetCreate)},
+		{http.MethodGet, "/api/v1/snippets/:id", api.ThenFunc(app.apiSnippetGet)},
+		{http.MethodPatch, "/api/v1/snippets/:id", apiProtected.ThenFunc(app.apiSnippetUpdate)},
+		{http.MethodDelete, "/api/v1/snippets/:id", apiProtected.ThenFunc(app.apiSnippetDelete)},
+	}
 }
@@ -4,8 +4,14 @@ import (
 	"html/template"
 	"io/fs"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"snippetbox.adpollak.net/internal/diff"
 	"snippetbox.adpollak.net/internal/models"
 	"snippetbox.adpollak.net/ui"
 )
@@ -16,11 +22,61 @@ type templateData struct {
 	CurrentYear     int // common dyn data we want to include on every page
 	Snippet         *models.Snippet
 	Snippets        []*models.Snippet
-	Form            any // used to pass validation errors and prev submitted data back to template when re-display the form
+	Code            []codeLine // the highlighted lines of the Snippet
+	Files           []fileCode // the further files of the Snippet, after its first
+	Form            any        // used to pass validation errors and prev submitted data back to template when re-display the form
 	Flash           string
 	IsAuthenticated bool
 	CSRFToken       string
 	User            *models.User
+	IsOwner         bool // whether the authenticated user owns the Snippet
+	Revealed        bool // whether the Snippet was revealed, using up one of its views
+	Starred         bool // whether the authenticated user starred the Snippet
+	Revisions       []*models.Revision
+	Comments        []*commentNode // the comment threads on the Snippet not attached to one of its lines
+	Commentable     bool           // whether the comments of the Snippet are shown and can be added to
+	Comment         *models.Comment
+	Revision        *models.Revision
+	DiffFrom        *models.Revision // the older revision being compared
+	DiffTo          *models.Revision // the newer revision being compared
+	Diff            []diff.Hunk
+	Query           string // the search query
+	Tag             string // the tag being listed, or searched within
+	TitlesOnly      bool   // whether search only matches titles, as content is encrypted at rest
+	TagCloud        []cloudTag
+	MostStarred     []*models.Snippet // the snippets starred most this week
+	Pagination      *pagination
+	Tokens          []*models.Token // the API tokens of the authenticated user
+	NewToken        string          // an API token just created, shown only this once
+}
+
+// A comment on a snippet and the replies to it, as rendered by the "comment"
+// partial template.
+type commentNode struct {
+	*models.Comment
+	Replies   []*commentNode
+	Own       bool // whether the authenticated user wrote the comment, so can edit or delete it
+	CanReply  bool // whether the user is logged in, so can reply to it
+	CSRFToken string
+}
+
+// A tag of the tag cloud on the home page, with the size it's shown at, from 1 to 5.
+type cloudTag struct {
+	*models.Tag
+	Size int
+}
+
+// Links to the previous and next pages of a listing, rendered by the
+// "pagination" partial template. An empty URL means there is no such page.
+type pagination struct {
+	PrevURL string
+	NextURL string
+}
+
+// One of the further files of a snippet, highlighted for the view page.
+type fileCode struct {
+	*models.File
+	Code []codeLine
 }
 
 // A function to cache our parsed tmpl files.
@@ -103,9 +159,87 @@ func humanDate(t time.Time) string {
 	return t.UTC().Format("02 Jan 2006 at 15:04")
 }
 
+// Return a case-insensitive regular expression matching any of the words
+// in a search query, or nil if the query has no words. Words are split the
+// same way as by the MySQL FULLTEXT parser, on anything but letters, digits
+// and underscores, so operators like + and " are ignored.
+func searchTermsRX(query string) *regexp.Regexp {
+	terms := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if len(terms) == 0 {
+		return nil
+	}
+
+	// Try longer terms first, so "snippets" is marked rather than just "snippet".
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	for i := range terms {
+		terms[i] = regexp.QuoteMeta(terms[i])
+	}
+
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
+// NOTE: custom function used in our Go template.
+// Escape text as HTML, wrapping every word of the search query in a <mark> element.
+func markMatches(text, query string) template.HTML {
+	rx := searchTermsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>" + template.HTMLEscapeString(text[loc[0]:loc[1]]) + "</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// NOTE: custom function used in our Go template.
+// Return roughly 200 bytes of text around the first word of the search query
+// it contains, with the words marked as by markMatches().
+func excerpt(text, query string) template.HTML {
+	const before, length = 60, 200
+
+	start := 0
+	if rx := searchTermsRX(query); rx != nil {
+		if loc := rx.FindStringIndex(text); loc != nil {
+			start = max(0, loc[0]-before)
+		}
+	}
+	end := min(len(text), start+length)
+
+	// Don't cut a multi-byte character in half.
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	html := markMatches(text[start:end], query)
+	if start > 0 {
+		html = "&hellip;" + html
+	}
+	if end < len(text) {
+		html += "&hellip;"
+	}
+
+	return html
+}
+
 // Initialize a template.FuncMap object and store it in a global var.
 // This is essentially a string-keyed map which acts as a lookup between the names
 // of our custom template functions and the functions themselves.
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":   humanDate,
+	"highlight":   highlight,
+	"languages":   func() []string { return languages },
+	"markMatches": markMatches,
+	"excerpt":     excerpt,
 }
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"snippetbox.adpollak.net/internal/assert"
 )
 
@@ -55,3 +57,141 @@ func TestHumanDate(t *testing.T) {
 		})
 	}
 }
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		language string
+		expected string
+	}{
+		{
+			name:     "Go",
+			content:  "func main() {}",
+			language: "Go",
+			expected: `<span class="kd">func</span>`,
+		},
+		{
+			name:     "Escapes HTML",
+			content:  "<script>alert(1)</script>",
+			language: "plaintext",
+			expected: "&lt;script&gt;alert(1)&lt;/script&gt;",
+		},
+		{
+			name:     "Unknown language",
+			content:  "SELECT 1;",
+			language: "Klingon",
+			expected: "SELECT 1;",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := highlight(tt.content, tt.language)
+
+			assert.NilError(t, err)
+			assert.StringContains(t, string(html), tt.expected)
+		})
+	}
+}
+
+// Every language offered by the language picker must have a lexer.
+func TestLanguagesHaveLexers(t *testing.T) {
+	for _, language := range languages {
+		if lexers.Get(language) == nil {
+			t.Errorf("no lexer for language %q", language)
+		}
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected lineRange
+	}{
+		{name: "Single line", value: "12", expected: lineRange{Start: 12, End: 12}},
+		{name: "Range", value: "12-20", expected: lineRange{Start: 12, End: 20}},
+		{name: "Fragment style", value: "L12-L20", expected: lineRange{Start: 12, End: 20}},
+		{name: "Backwards", value: "L20-L12", expected: lineRange{Start: 12, End: 20}},
+		{name: "Empty", value: "", expected: lineRange{}},
+		{name: "Zero", value: "0-3", expected: lineRange{}},
+		{name: "Not a number", value: "L1-foo", expected: lineRange{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, parseLineRange(tt.value), tt.expected)
+		})
+	}
+}
+
+func TestHighlightLines(t *testing.T) {
+	lines, err := highlightLines("a := 1\nb := 2\nc := 3\n", "Go", lineRange{Start: 2, End: 3})
+	assert.NilError(t, err)
+
+	assert.Equal(t, len(lines), 3)
+	for i, line := range lines {
+		assert.Equal(t, line.Number, i+1)
+		assert.Equal(t, line.Highlighted, i > 0)
+	}
+	assert.StringContains(t, string(lines[1].HTML), `<span class="nx">b</span>`)
+}
+
+func TestLineCount(t *testing.T) {
+	assert.Equal(t, lineCount(""), 1)
+	assert.Equal(t, lineCount("a := 1"), 1)
+	assert.Equal(t, lineCount("a := 1\nb := 2\n"), 2)
+	assert.Equal(t, lineCount("a := 1\n\nb := 2"), 3)
+}
+
+func TestMarkMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "Single word",
+			text:     "An old silent pond",
+			query:    "pond",
+			expected: "An old silent <mark>pond</mark>",
+		},
+		{
+			name:     "Case insensitive words",
+			text:     "An old silent pond",
+			query:    "+OLD pond*",
+			expected: "An <mark>old</mark> silent <mark>pond</mark>",
+		},
+		{
+			name:     "Escapes HTML",
+			text:     "<b>pond</b>",
+			query:    "pond",
+			expected: "&lt;b&gt;<mark>pond</mark>&lt;/b&gt;",
+		},
+		{
+			name:     "No words",
+			text:     "An old silent pond",
+			query:    `"+"`,
+			expected: "An old silent pond",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, string(markMatches(tt.text, tt.query)), tt.expected)
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	text := strings.Repeat("a ", 100) + "pond" + strings.Repeat(" b", 100)
+
+	html := string(excerpt(text, "pond"))
+
+	assert.StringContains(t, html, "<mark>pond</mark>")
+	assert.Equal(t, strings.HasPrefix(html, "&hellip;"), true)
+	assert.Equal(t, strings.HasSuffix(html, "&hellip;"), true)
+	assert.Equal(t, string(excerpt("short", "pond")), "short")
+}
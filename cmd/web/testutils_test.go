@@ -14,36 +14,38 @@ import (
 	"time"
 
 	"github.com/alexedwards/scs/v2"
-	"github.com/go-playground/form/v4"
 	"snippetbox.adpollak.net/internal/models/mocks"
 )
 
-func newTestApplication(t *testing.T) *application {
-	// Instance of template cache
-	templateCache, err := newTemplateCache()
+// Return the application as main() builds it, configured by the given
+// command-line flags, with mock models and an in-memory session store.
+func newTestApplication(t *testing.T, args ...string) *application {
+	cfg, err := parseFlags(args)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// Form decoder
-	formDecoder := form.NewDecoder()
+	app, err := newApplication(cfg, log.New(io.Discard, "", 0), log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app.snippets = &mocks.SnippetModel{}
+	app.users = &mocks.UserModel{}
+	app.revisions = &mocks.RevisionModel{}
+	app.tags = &mocks.TagModel{}
+	app.stars = &mocks.StarModel{}
+	app.comments = &mocks.CommentModel{}
+	app.tokens = &mocks.TokenModel{}
 
 	// Session manager instance. Same settings as production,
 	// except we don't set a Store for session manager, so as to
 	// use the default transient in-memory store, useful for testing.
-	sessionManager := scs.New()
-	sessionManager.Lifetime = 12 * time.Hour
-	sessionManager.Cookie.Secure = true
-
-	return &application{
-		errorLog:       log.New(io.Discard, "", 0),
-		infoLog:        log.New(io.Discard, "", 0),
-		snippets:       &mocks.SnippetModel{},
-		users:          &mocks.UserModel{},
-		templateCache:  templateCache,
-		formDecoder:    formDecoder,
-		sessionManager: sessionManager,
-	}
+	app.sessionManager = scs.New()
+	app.sessionManager.Lifetime = 12 * time.Hour
+	app.sessionManager.Cookie.Secure = true
+
+	return app
 }
 
 // Define a regex that captures the CSRF token value from the
@@ -134,3 +136,46 @@ func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (i
 	// Return the response status, headers and body.
 	return rs.StatusCode, rs.Header, string(body)
 }
+
+// Sends a request with any method, headers and body to the test server, and
+// returns the response status code, headers and body.
+func (ts *testServer) request(t *testing.T, method, urlPath string, header http.Header, body io.Reader) (int, http.Header, string) {
+	req, err := http.NewRequest(method, ts.URL+urlPath, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rs.Body.Close()
+	rsBody, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs.StatusCode, rs.Header, string(rsBody)
+}
+
+// Logs in as the mock user alice@example.com, mimicking the workflow of
+// a user visiting the login page, and submitting the form with its CSRF token.
+// The session cookie is stored in the test server client's cookie jar.
+func (ts *testServer) login(t *testing.T) {
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	form := url.Values{}
+	form.Add("email", "alice@example.com")
+	form.Add("password", "pa$$word")
+	form.Add("csrf_token", validCSRFToken)
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login failed with status %d", code)
+	}
+}
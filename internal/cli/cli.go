@@ -0,0 +1,365 @@
+// Package cli is the command-line client of snippetbox, built as cmd/snippet.
+// It lives here rather than in cmd/snippet so the tests of cmd/web can run it
+// against the web application itself.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+const usage = `Usage: snippet [-config path] [-server url] <command> [flags] [arguments]
+
+Commands:
+  login <server>        save the server and an API token, read from stdin, to the config file
+  create [file ...]     create a snippet from files, or from stdin without any
+  get <id>              print the content of a snippet
+  list                  list your snippets
+  search [query ...]    search public snippets
+  delete <id>           delete one of your snippets
+
+Run snippet <command> -h for the flags of a command.
+`
+
+// Returned by Run when the command line is invalid, after the usage is printed.
+var ErrUsage = errors.New("cli: invalid usage")
+
+// The command-line client. Its fields are set by cmd/snippet to the process's
+// standard streams, and by tests to buffers and the test server's client.
+type CLI struct {
+	Stdin      io.Reader
+	Stdout     io.Writer
+	Stderr     io.Writer
+	ConfigPath string       // empty for DefaultConfigPath()
+	HTTPClient *http.Client // nil to use http.DefaultClient
+}
+
+// Run the command given by args, the command-line arguments without the
+// program's name.
+func (c *CLI) Run(args []string) error {
+	flags := c.flagSet("snippet", usage)
+	configPath := flags.String("config", c.ConfigPath, "the config file")
+	server := flags.String("server", "", "the URL of the server, in place of the config file's")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	if *configPath == "" {
+		var err error
+		*configPath, err = DefaultConfigPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", *configPath, err)
+	}
+	if *server != "" {
+		cfg.Server = *server
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprint(c.Stderr, usage)
+		return ErrUsage
+	}
+	command, args := flags.Arg(0), flags.Args()[1:]
+
+	if command == "login" {
+		return c.login(*configPath, cfg, args)
+	}
+
+	if cfg.Server == "" {
+		return errors.New("no server is configured; run snippet login <server> first")
+	}
+	client := &Client{BaseURL: cfg.Server, Token: cfg.Token, HTTPClient: c.HTTPClient}
+
+	switch command {
+	case "create":
+		return c.create(client, args)
+	case "get":
+		return c.get(client, args)
+	case "list":
+		return c.list(client, args)
+	case "search":
+		return c.search(client, args)
+	case "delete":
+		return c.delete(client, args)
+	default:
+		fmt.Fprintf(c.Stderr, "snippet: unknown command %q\n\n%s", command, usage)
+		return ErrUsage
+	}
+}
+
+// Return a flag set writing its errors and usage to c.Stderr.
+func (c *CLI) flagSet(name, usage string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.Stderr)
+	flags.Usage = func() {
+		fmt.Fprint(c.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// Parse args with flags, which has already reported any error.
+func (c *CLI) parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		return ErrUsage
+	}
+	return nil
+}
+
+// Saves the server and an API token to the config file, once the token was
+// checked against the server. The token is read from stdin rather than an
+// argument, so it doesn't end up in the shell's history.
+func (c *CLI) login(configPath string, cfg *Config, args []string) error {
+	flags := c.flagSet("login", "Usage: snippet login <server>\n\nThe API token is read from stdin; create one at <server>/account/tokens.\n")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return ErrUsage
+	}
+	if flags.NArg() == 1 {
+		cfg.Server = strings.TrimSuffix(flags.Arg(0), "/")
+	}
+	if cfg.Server == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	fmt.Fprint(c.Stderr, "API token: ")
+	token, err := bufio.NewReader(c.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	cfg.Token = strings.TrimSpace(token)
+	if cfg.Token == "" {
+		return errors.New("no API token was given")
+	}
+
+	client := &Client{BaseURL: cfg.Server, Token: cfg.Token, HTTPClient: c.HTTPClient}
+	if _, err := client.Mine(); err != nil {
+		return fmt.Errorf("checking the token: %w", err)
+	}
+
+	if err := cfg.Save(configPath); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "Logged in to %s\n", cfg.Server)
+	return nil
+}
+
+// Creates a snippet from the files given, or from stdin without any, and
+// prints its URL.
+func (c *CLI) create(client *Client, args []string) error {
+	flags := c.flagSet("create", "Usage: snippet create [flags] [file ...]\n\nWithout files, or with the file -, the snippet is read from stdin.\n\n")
+	title := flags.String("title", "", "the title, by default the name of the first file")
+	language := flags.String("language", "", "the language of the first file, by default detected")
+	tags := flags.String("tags", "", "tags separated by spaces or commas")
+	visibility := flags.String("visibility", "", "public, unlisted or private (default public)")
+	expires := flags.String("expires", "", "days to keep it for, 1, 7 or 365, or never (default 365)")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+
+	var files []File
+	for _, name := range names {
+		var (
+			content []byte
+			err     error
+		)
+		if name == "-" {
+			content, err = io.ReadAll(c.Stdin)
+			name = ""
+		} else {
+			content, err = os.ReadFile(name)
+			name = filepath.Base(name)
+		}
+		if err != nil {
+			return err
+		}
+		files = append(files, File{Filename: name, Content: string(content)})
+	}
+
+	s := &NewSnippet{
+		Title:      *title,
+		Filename:   files[0].Filename,
+		Content:    files[0].Content,
+		Language:   *language,
+		Files:      files[1:],
+		Tags:       strings.FieldsFunc(*tags, func(r rune) bool { return r == ',' || r == ' ' }),
+		Visibility: *visibility,
+		Expires:    *expires,
+	}
+	if s.Title == "" {
+		s.Title = s.Filename
+	}
+	if s.Title == "" {
+		s.Title = "Untitled"
+	}
+
+	created, err := client.Create(s)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(c.Stdout, strings.TrimSuffix(client.BaseURL, "/")+created.URL)
+	return nil
+}
+
+// Prints the content of a snippet's first file, or of the file named by -file.
+// The snippet is named by its ID or the URL of its view page.
+func (c *CLI) get(client *Client, args []string) error {
+	flags := c.flagSet("get", "Usage: snippet get [flags] <id>\n\n")
+	filename := flags.String("file", "", "the file of the snippet to print, by default its first")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	s, err := client.Get(snippetID(flags.Arg(0)))
+	if err != nil {
+		return err
+	}
+
+	// Only the browser holds the key of an encrypted snippet.
+	if s.Encrypted {
+		return fmt.Errorf("snippet %s is encrypted, so can only be read in a browser with its full link", s.ID)
+	}
+
+	content := s.Content
+	if *filename != "" && *filename != s.Filename {
+		found := false
+		for _, f := range s.Files {
+			if f.Filename == *filename {
+				content, found = f.Content, true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("snippet %s has no file named %q", s.ID, *filename)
+		}
+	}
+
+	_, err = io.WriteString(c.Stdout, content)
+	return err
+}
+
+// Lists the snippets of the user of the token.
+func (c *CLI) list(client *Client, args []string) error {
+	flags := c.flagSet("list", "Usage: snippet list\n")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	snippets, err := client.Mine()
+	if err != nil {
+		return err
+	}
+
+	if len(snippets) == 0 {
+		fmt.Fprintln(c.Stderr, "You haven't created any snippets yet.")
+		return nil
+	}
+	return c.printSnippets(snippets)
+}
+
+// Searches the public snippets, printing a page of them.
+func (c *CLI) search(client *Client, args []string) error {
+	flags := c.flagSet("search", "Usage: snippet search [flags] [query ...]\n\n")
+	tag := flags.String("tag", "", "only search snippets with this tag")
+	page := flags.Int("page", 1, "the page of results")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+
+	query := strings.Join(flags.Args(), " ")
+	if query == "" && *tag == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	list, err := client.Search(query, *tag, *page)
+	if err != nil {
+		return err
+	}
+
+	if len(list.Snippets) == 0 {
+		fmt.Fprintln(c.Stderr, "No snippets found.")
+		return nil
+	}
+	if err := c.printSnippets(list.Snippets); err != nil {
+		return err
+	}
+	if list.Next != "" {
+		fmt.Fprintf(c.Stderr, "There are more results; run again with -page %d.\n", *page+1)
+	}
+	return nil
+}
+
+// Deletes a snippet of the user of the token.
+func (c *CLI) delete(client *Client, args []string) error {
+	flags := c.flagSet("delete", "Usage: snippet delete <id>\n")
+	if err := c.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	id := snippetID(flags.Arg(0))
+	if err := client.Delete(id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "Deleted %s\n", id)
+	return nil
+}
+
+// Print snippets as a table of their ID, visibility, expiry and title.
+func (c *CLI) printSnippets(snippets []*Snippet) error {
+	tw := tabwriter.NewWriter(c.Stdout, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ID\tVISIBILITY\tEXPIRES\tTITLE")
+	for _, s := range snippets {
+		expires := "never"
+		if s.Expires != nil {
+			expires = s.Expires.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.ID, s.Visibility, expires, s.Title)
+	}
+
+	return tw.Flush()
+}
+
+// Return the ID of a snippet given as its ID, or as the URL of its view page,
+// which may have a query string or fragment.
+func snippetID(arg string) string {
+	if i := strings.IndexAny(arg, "?#"); i >= 0 {
+		arg = arg[:i]
+	}
+	return path.Base(arg)
+}
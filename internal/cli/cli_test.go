@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+func TestSnippetID(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{
+			name: "ID",
+			arg:  "oldPond1",
+			want: "oldPond1",
+		},
+		{
+			name: "URL",
+			arg:  "https://snippetbox.example.com/snippet/view/oldPond1",
+			want: "oldPond1",
+		},
+		{
+			name: "URL with lines",
+			arg:  "https://snippetbox.example.com/snippet/view/oldPond1?lines=2-3#L2-L3",
+			want: "oldPond1",
+		},
+		{
+			name: "URL with a key",
+			arg:  "https://snippetbox.example.com/snippet/view/encNote7#key=c2VjcmV0",
+			want: "encNote7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, snippetID(tt.arg), tt.want)
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snippetbox", "config.json")
+
+	t.Run("Missing", func(t *testing.T) {
+		cfg, err := LoadConfig(path)
+
+		assert.NilError(t, err)
+		assert.Equal(t, *cfg, Config{})
+	})
+
+	t.Run("Saved", func(t *testing.T) {
+		want := Config{Server: "https://snippetbox.example.com", Token: "sbx_write"}
+		assert.NilError(t, want.Save(path))
+
+		cfg, err := LoadConfig(path)
+
+		assert.NilError(t, err)
+		assert.Equal(t, *cfg, want)
+	})
+}
+
+func TestError(t *testing.T) {
+	err := &Error{
+		StatusCode:     422,
+		Message:        "The request failed validation",
+		FieldErrors:    map[string]string{"title": "This field cannot be blank", "content": "This field cannot be blank"},
+		NonFieldErrors: []string{"Something went wrong"},
+	}
+
+	assert.Equal(t, err.Error(), "The request failed validation\nSomething went wrong\n  content: This field cannot be blank\n  title: This field cannot be blank")
+}
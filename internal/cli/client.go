@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A client of the JSON API of a snippetbox server.
+type Client struct {
+	BaseURL    string       // the URL of the server, like https://snippetbox.example.com
+	Token      string       // a personal API token, or empty to make anonymous requests
+	HTTPClient *http.Client // nil to use http.DefaultClient
+}
+
+// A file of a snippet, after its first.
+type File struct {
+	Filename string `json:"filename"`
+	Language string `json:"language,omitempty"`
+	Content  string `json:"content"`
+}
+
+// A snippet, as sent by the API.
+type Snippet struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"` // the path of its view page
+	Title          string     `json:"title"`
+	Filename       string     `json:"filename"`
+	Language       string     `json:"language"`
+	Content        string     `json:"content"` // empty in listings
+	Files          []File     `json:"files"`   // empty in listings
+	Tags           []string   `json:"tags"`
+	Visibility     string     `json:"visibility"`
+	Author         string     `json:"author"`
+	Created        time.Time  `json:"created"`
+	Expires        *time.Time `json:"expires"` // nil if the snippet never expires
+	ViewsRemaining int        `json:"views_remaining"`
+	Protected      bool       `json:"protected"`
+	Encrypted      bool       `json:"encrypted"`
+	ForkedFrom     string     `json:"forked_from"`
+	Forks          int        `json:"forks"`
+	Stars          int        `json:"stars"`
+}
+
+// A snippet to create. Empty fields are left to the server's defaults.
+type NewSnippet struct {
+	Title      string   `json:"title"`
+	Filename   string   `json:"filename,omitempty"`
+	Content    string   `json:"content"`
+	Language   string   `json:"language,omitempty"`
+	Files      []File   `json:"files,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+	Expires    string   `json:"expires,omitempty"`
+}
+
+// A page of a listing of snippets, with the URLs of the pages around it, which
+// are empty on the first and last pages.
+type List struct {
+	Snippets []*Snippet `json:"snippets"`
+	Next     string     `json:"next"`
+	Prev     string     `json:"prev"`
+}
+
+// An error response from the API. Failed validations carry the error of each
+// invalid field, and those not about a single field.
+type Error struct {
+	StatusCode     int               `json:"-"`
+	Message        string            `json:"error"`
+	FieldErrors    map[string]string `json:"field_errors"`
+	NonFieldErrors []string          `json:"non_field_errors"`
+}
+
+func (e *Error) Error() string {
+	lines := []string{e.Message}
+	lines = append(lines, e.NonFieldErrors...)
+
+	fields := make([]string, 0, len(e.FieldErrors))
+	for field := range e.FieldErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		lines = append(lines, "  "+field+": "+e.FieldErrors[field])
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Create a snippet owned by the user of the token.
+func (c *Client) Create(s *NewSnippet) (*Snippet, error) {
+	created := &Snippet{}
+	err := c.do(http.MethodPost, "/api/v1/snippets", s, created)
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Return a snippet with its content and files.
+func (c *Client) Get(id string) (*Snippet, error) {
+	s := &Snippet{}
+	err := c.do(http.MethodGet, "/api/v1/snippets/"+url.PathEscape(id), nil, s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Return every unexpired snippet of the user of the token, newest first.
+func (c *Client) Mine() ([]*Snippet, error) {
+	list := &List{}
+	err := c.do(http.MethodGet, "/api/v1/user/snippets", nil, list)
+	if err != nil {
+		return nil, err
+	}
+	return list.Snippets, nil
+}
+
+// Return a page of the public snippets matching query, or tagged with tag, or
+// both. Pages are numbered from 1.
+func (c *Client) Search(query, tag string, page int) (*List, error) {
+	values := url.Values{}
+	if query != "" {
+		values.Set("q", query)
+	}
+	if tag != "" {
+		values.Set("tag", tag)
+	}
+	if page > 1 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	list := &List{}
+	err := c.do(http.MethodGet, "/api/v1/search?"+values.Encode(), nil, list)
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Delete a snippet owned by the user of the token.
+func (c *Client) Delete(id string) error {
+	return c.do(http.MethodDelete, "/api/v1/snippets/"+url.PathEscape(id), nil, nil)
+}
+
+// Send a request to the API, with body encoded as JSON unless it's nil, and
+// decode the response into dst unless it's nil. Error responses are returned
+// as an *Error.
+func (c *Client) do(method, path string, body, dst any) error {
+	var r io.Reader
+	if body != nil {
+		js, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, strings.TrimSuffix(c.BaseURL, "/")+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	rs, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode >= 400 {
+		apiErr := &Error{StatusCode: rs.StatusCode}
+		// Responses which aren't from the API, such as from a proxy, are
+		// reported by their status alone.
+		if json.NewDecoder(rs.Body).Decode(apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = rs.Status
+		}
+		return apiErr
+	}
+
+	if dst == nil {
+		return nil
+	}
+	if err := json.NewDecoder(rs.Body).Decode(dst); err != nil {
+		return fmt.Errorf("decoding the response of %s %s: %w", method, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// The configuration of the CLI, kept in a JSON file in the user's config
+// directory: the server it talks to, and the API token it authenticates with.
+type Config struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+}
+
+// Return where the config file is kept by default, such as
+// ~/.config/snippetbox/config.json on Linux.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snippetbox", "config.json"), nil
+}
+
+// Read the config file at path. A missing file gives an empty Config, so the
+// CLI can be used anonymously with -server before logging in.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+
+	js, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(js, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Write the config to the file at path, creating its directory if needed.
+// NOTE: the file holds an API token, so only the user can read it.
+func (cfg *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	js, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(js, '\n'), 0600)
+}
@@ -0,0 +1,295 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The kind of change a line in a diff represents.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// Returns the name of the operation, used as a CSS class when rendering diffs.
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	default:
+		return "equal"
+	}
+}
+
+// A single line of a diff. OldNumber and NewNumber are the 1-based line numbers
+// of the line in the old and new text, and are 0 if the line isn't in that text.
+type Line struct {
+	Op        Op
+	Text      string
+	OldNumber int
+	NewNumber int
+}
+
+// Returns the unified diff prefix of a line: "+", "-" or " ".
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Insert:
+		return "+"
+	case Delete:
+		return "-"
+	default:
+		return " "
+	}
+}
+
+// A contiguous group of changed lines, surrounded by unchanged context lines.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Returns the "@@ -1,3 +1,4 @@" header of the hunk.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Split text into lines. A trailing newline doesn't start a new line, and
+// Windows line endings are treated the same as Unix ones.
+func splitLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// Compare the old and new text line-by-line and return every line of both,
+// in order, marked with whether it was kept, inserted or deleted.
+func Lines(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+
+	// Trim the common prefix and suffix first; most edits only touch a few
+	// lines, and this keeps the work done by myers() small.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, Equal)
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := 0; i < suffix; i++ {
+		ops = append(ops, Equal)
+	}
+
+	// Walk both texts alongside the operations to number the lines.
+	lines := make([]Line, 0, len(ops))
+	x, y := 0, 0
+	for _, op := range ops {
+		switch op {
+		case Equal:
+			lines = append(lines, Line{Op: Equal, Text: a[x], OldNumber: x + 1, NewNumber: y + 1})
+			x++
+			y++
+		case Delete:
+			lines = append(lines, Line{Op: Delete, Text: a[x], OldNumber: x + 1})
+			x++
+		case Insert:
+			lines = append(lines, Line{Op: Insert, Text: b[y], NewNumber: y + 1})
+			y++
+		}
+	}
+
+	return lines
+}
+
+// The most edits myers() searches for. Beyond that the texts are too different
+// for a line-by-line diff to help, and the search would take too long: its time
+// grows with the number of lines times the number of edits, and its memory with
+// the square of the number of edits.
+// NOTE: anyone can diff the revisions of a public snippet, so this keeps a
+// diff of two large, completely different revisions from exhausting the server.
+const maxEdits = 1000
+
+// Return the shortest edit script turning a into b, using the greedy algorithm
+// from Eugene Myers' "An O(ND) Difference Algorithm and Its Variations". If it
+// takes more than maxEdits, every line of a is deleted and every line of b
+// inserted instead.
+func myers(a, b []string) []Op {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	if n+m == 0 {
+		return nil
+	}
+
+	// v[offset+k] holds the furthest x reached on diagonal k. To backtrack the
+	// path once we reach the end, we keep a copy of the part of v step d reads,
+	// the diagonals -d to d, before every step.
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	found := false
+search:
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down, an insertion
+			} else {
+				x = v[offset+k-1] + 1 // move right, a deletion
+			}
+			y := x - k
+
+			// Follow the diagonal as far as the lines are equal.
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break search
+			}
+		}
+	}
+
+	if !found {
+		ops := make([]Op, 0, n+m)
+		for range n {
+			ops = append(ops, Delete)
+		}
+		for range m {
+			ops = append(ops, Insert)
+		}
+		return ops
+	}
+
+	// Backtrack from the end to the start, collecting operations in reverse.
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// trace[d][d+k] holds the x of diagonal k before step d.
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[d+prevK]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, Equal)
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, Insert)
+			} else {
+				ops = append(ops, Delete)
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
+
+// Compare the old and new text and group the changes into hunks, each with up
+// to context unchanged lines either side, in the style of `diff -u`.
+// Returns nil if the texts have the same lines.
+func Unified(old, new string, context int) []Hunk {
+	lines := Lines(old, new)
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// Extend the hunk over every change that is within 2*context lines of
+		// the previous one, so that hunks don't overlap.
+		start := max(0, i-context)
+		end := i
+		for j := i; j < len(lines); j++ {
+			if lines[j].Op != Equal {
+				end = j
+			} else if j-end > 2*context {
+				break
+			}
+		}
+		end = min(len(lines), end+context+1)
+
+		hunks = append(hunks, newHunk(lines, start, end))
+		i = end
+	}
+
+	return hunks
+}
+
+// Build the hunk for lines[start:end], counting the lines from each text.
+func newHunk(lines []Line, start, end int) Hunk {
+	h := Hunk{Lines: lines[start:end]}
+
+	// Count how many lines of each text come before the hunk.
+	for _, l := range lines[:start] {
+		if l.Op != Insert {
+			h.OldStart++
+		}
+		if l.Op != Delete {
+			h.NewStart++
+		}
+	}
+
+	for _, l := range h.Lines {
+		if l.Op != Insert {
+			h.OldLines++
+		}
+		if l.Op != Delete {
+			h.NewLines++
+		}
+	}
+
+	// Like diff -u, a range is numbered from its first line, or from the line
+	// before it when it's empty.
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+
+	return h
+}
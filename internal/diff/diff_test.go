@@ -0,0 +1,124 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+// Render lines in the unified diff format, one per line.
+func render(lines []Line) string {
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.Prefix() + l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+	}{
+		{
+			name:     "Identical",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			expected: " a\n b\n",
+		},
+		{
+			name:     "Empty old",
+			old:      "",
+			new:      "a\nb",
+			expected: "+a\n+b\n",
+		},
+		{
+			name:     "Empty new",
+			old:      "a\nb",
+			new:      "",
+			expected: "-a\n-b\n",
+		},
+		{
+			name:     "Changed line",
+			old:      "a\nb\nc",
+			new:      "a\nB\nc",
+			expected: " a\n-b\n+B\n c\n",
+		},
+		{
+			name:     "Inserted and deleted",
+			old:      "a\nb\nc\nd",
+			new:      "b\nc\ne\nd",
+			expected: "-a\n b\n c\n+e\n d\n",
+		},
+		{
+			name:     "Windows line endings",
+			old:      "a\r\nb\r\n",
+			new:      "a\nb\n",
+			expected: " a\n b\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, render(Lines(tt.old, tt.new)), tt.expected)
+		})
+	}
+}
+
+// Texts needing more than maxEdits edits are diffed as a whole, rather than
+// line by line, so large completely different revisions can't exhaust the server.
+func TestLinesMaxEdits(t *testing.T) {
+	// Return count numbered lines, each starting with prefix.
+	numbered := func(prefix string, count int) string {
+		var b strings.Builder
+		for i := range count {
+			b.WriteString(prefix + strconv.Itoa(i) + "\n")
+		}
+		return b.String()
+	}
+
+	t.Run("Within the limit", func(t *testing.T) {
+		// Every other line changes, which is exactly maxEdits edits.
+		var old, new strings.Builder
+		for i := range maxEdits / 2 {
+			old.WriteString("same\nold" + strconv.Itoa(i) + "\n")
+			new.WriteString("same\nnew" + strconv.Itoa(i) + "\n")
+		}
+
+		lines := Lines(old.String(), new.String())
+
+		assert.Equal(t, len(lines), maxEdits/2*3)
+		assert.Equal(t, render(lines[:3]), " same\n-old0\n+new0\n")
+	})
+
+	t.Run("Beyond the limit", func(t *testing.T) {
+		old := "first\n" + numbered("old", 30000) + "last\n"
+		new := "first\n" + numbered("new", 30000) + "last\n"
+
+		lines := Lines(old, new)
+
+		assert.Equal(t, len(lines), 60002)
+		assert.Equal(t, render(lines[:2]), " first\n-old0\n")
+		assert.Equal(t, render(lines[30000:30002]), "-old29999\n+new0\n")
+		assert.Equal(t, render(lines[60001:]), " last\n")
+	})
+}
+
+func TestUnified(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	hunks := Unified(old, new, 2)
+
+	assert.Equal(t, len(hunks), 2)
+	assert.Equal(t, hunks[0].Header(), "@@ -1,5 +1,5 @@")
+	assert.Equal(t, render(hunks[0].Lines), " 1\n 2\n-3\n+three\n 4\n 5\n")
+	assert.Equal(t, hunks[1].Header(), "@@ -11,2 +11,3 @@")
+	assert.Equal(t, render(hunks[1].Lines), " 11\n 12\n+13\n")
+
+	assert.Equal(t, len(Unified(old, old, 3)), 0)
+}
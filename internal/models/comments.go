@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type CommentModelInterface interface {
+	Insert(c *Comment) (int, error)
+	Get(id int) (*Comment, error)
+	ForSnippet(snippetID int) ([]*Comment, error)
+	Update(id int, body string) error
+	Delete(id int) error
+}
+
+// A comment on a snippet. A comment either starts a thread, optionally attached
+// to a line of the snippet, or replies to another comment of the same snippet.
+type Comment struct {
+	ID             int
+	SnippetID      int
+	SnippetShortID string
+	UserID         int
+	Author         string
+	ParentID       int // the comment this one replies to, or 0 if it starts a thread
+	Line           int // the line of the snippet's first file it's about, or 0 for the whole snippet
+	Body           string
+	Created        time.Time
+	Updated        time.Time // zero if the comment was never edited
+}
+
+// The longest a comment can be, in characters.
+const MaxCommentLength = 2000
+
+// Wrap the database connection pool.
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// The columns of a comment, in the order scanComment() reads them, and the
+// tables they're selected from. Comments are joined against their snippet for
+// its short ID, and their author for their name.
+const (
+	commentColumns = `c.id, c.snippet_id, s.short_id, c.user_id, u.name, COALESCE(c.parent_id, 0), COALESCE(c.line, 0), c.body, c.created, c.updated`
+	commentTables  = `comments c JOIN snippets s ON s.id = c.snippet_id JOIN users u ON u.id = c.user_id`
+)
+
+// Copy a single tuple into a new Comment.
+func scanComment(row scanner) (*Comment, error) {
+	c := &Comment{}
+	// updated is NULL for comments which were never edited, leaving c.Updated zero.
+	var updated sql.NullTime
+
+	err := row.Scan(&c.ID, &c.SnippetID, &c.SnippetShortID, &c.UserID, &c.Author, &c.ParentID, &c.Line, &c.Body, &c.Created, &updated)
+	if err != nil {
+		return nil, err
+	}
+	c.Updated = updated.Time
+
+	return c, nil
+}
+
+// Insert a comment by c.UserID on the snippet c.SnippetID, replying to c.ParentID
+// or attached to c.Line if either isn't 0, and return its ID.
+// NOTE: checking the comment replied to is on the same snippet is left to the handler.
+func (m *CommentModel) Insert(c *Comment) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, user_id, parent_id, line, body, created)
+  VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	// Threads are started with a NULL parent_id, and comments on the whole snippet have a NULL line.
+	parentID := sql.NullInt64{Int64: int64(c.ParentID), Valid: c.ParentID != 0}
+	line := sql.NullInt64{Int64: int64(c.Line), Valid: c.Line != 0}
+
+	result, err := m.DB.Exec(stmt, c.SnippetID, c.UserID, parentID, line, c.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// Return the comment with the given ID, if its snippet hasn't expired.
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM ` + commentTables + `
+  WHERE c.id = ? AND ` + unexpired
+
+	c, err := scanComment(m.DB.QueryRow(stmt, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Return every comment on a snippet, oldest first, so a comment always comes
+// after the one it replies to.
+func (m *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	stmt := `SELECT ` + commentColumns + ` FROM ` + commentTables + `
+  WHERE c.snippet_id = ? ORDER BY c.id`
+
+	tuples, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer tuples.Close()
+
+	comments := []*Comment{}
+
+	for tuples.Next() {
+		c, err := scanComment(tuples)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = tuples.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// Change the body of a comment, recording when it was edited.
+// NOTE: checking the comment was written by the user making the change is left to the handler.
+func (m *CommentModel) Update(id int, body string) error {
+	stmt := `UPDATE comments SET body = ?, updated = UTC_TIMESTAMP() WHERE id = ?`
+
+	_, err := m.DB.Exec(stmt, body, id)
+	return err
+}
+
+// Delete a comment, and with it every reply in its thread.
+// Returns ErrNoRecord if no comment with the given id exists.
+func (m *CommentModel) Delete(id int) error {
+	stmt := `DELETE FROM comments WHERE id = ?`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
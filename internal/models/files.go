@@ -0,0 +1,74 @@
+package models
+
+import "database/sql"
+
+// One of the further files of a multi-file snippet, like the go.mod alongside
+// a main.go. The first file of every snippet is the snippet's own Content and
+// Language, named Filename; only the files after it are stored in snippet_files.
+type File struct {
+	Filename string
+	Language string // name of the language the content is highlighted as
+	Content  string
+}
+
+// The most files a snippet can have, including its first file.
+const MaxFiles = 20
+
+// Either a *sql.DB or *sql.Tx, both of which we read files with.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Insert the further files of a snippet, in order, encrypting their content with keys.
+// Runs inside the transaction that created the snippet.
+func insertFiles(tx *sql.Tx, keys *Keyring, snippetID int, files []*File) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, filename, language, ` + contentColumns + `)
+  VALUES(?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for i, f := range files {
+		content, err := keys.seal(f.Content)
+		if err != nil {
+			return err
+		}
+
+		// The first file of the snippet is at position 0, so its further files start at 1.
+		_, err = tx.Exec(stmt, snippetID, i+1, f.Filename, f.Language, content.Plaintext, content.Ciphertext, content.WrappedKey, content.KeyID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the further files of a snippet into s.Files, decrypting their content with keys.
+func loadFiles(q querier, keys *Keyring, s *Snippet) error {
+	stmt := `SELECT filename, language, ` + contentColumns + ` FROM snippet_files
+  WHERE snippet_id = ? ORDER BY position`
+
+	tuples, err := q.Query(stmt, s.ID)
+	if err != nil {
+		return err
+	}
+	defer tuples.Close()
+
+	s.Files = nil
+
+	for tuples.Next() {
+		f := &File{}
+		var content sealedContent
+
+		err := tuples.Scan(&f.Filename, &f.Language, &content.Plaintext, &content.Ciphertext, &content.WrappedKey, &content.KeyID)
+		if err != nil {
+			return err
+		}
+
+		f.Content, err = keys.open(content)
+		if err != nil {
+			return err
+		}
+		s.Files = append(s.Files, f)
+	}
+
+	return tuples.Err()
+}
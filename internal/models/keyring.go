@@ -0,0 +1,348 @@
+package models
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// The size of master keys and data keys: 32 bytes, for AES-256.
+const keySize = 32
+
+// Returned when content can't be decrypted: the master key it was wrapped with
+// isn't in the keyring, or the content (or the wrapped data key) was tampered with.
+var ErrUndecryptable = errors.New("models: content can't be decrypted")
+
+// Holds the master keys used for encrypting the content of snippets at rest.
+//
+// NOTE: this is envelope encryption. The content of each snippet (and of each of
+// its revisions) is encrypted with a random data key of its own, and the data key
+// is stored alongside it, wrapped (encrypted) with a master key. The master keys
+// themselves are never stored in the database, so a dump of it doesn't expose
+// any content.
+//
+// New data keys are always wrapped with the current master key. The other keys
+// are kept to unwrap data keys wrapped before the master key was rotated, until
+// Rekey() has re-wrapped all of them with the current one.
+type Keyring struct {
+	current string
+	keys    map[string][]byte
+}
+
+// Parse a keyring from text holding one master key per line as
+// `<key id>=<key>`, where the key is 32 random bytes, base64 encoded. The first
+// key is the current one. Keys may also be separated by commas instead of
+// newlines, to fit in an environment variable; blank lines and lines starting with
+// # are skipped.
+//
+// A key can be generated with: echo "$(date +%Y%m%d)=$(head -c 32 /dev/urandom | base64)"
+func ParseKeyring(text string) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte)}
+
+	for _, line := range strings.FieldsFunc(text, func(r rune) bool { return r == '\n' || r == ',' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(line, "=")
+		id = strings.TrimSpace(id)
+		if !ok || id == "" {
+			return nil, fmt.Errorf("models: master key %q isn't of the form <key id>=<key>", id)
+		}
+		if len(id) > 64 {
+			return nil, fmt.Errorf("models: master key ID %q is longer than 64 characters", id)
+		}
+		if _, exists := k.keys[id]; exists {
+			return nil, fmt.Errorf("models: master key %q is given twice", id)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != keySize {
+			return nil, fmt.Errorf("models: master key %q isn't %d base64 encoded bytes", id, keySize)
+		}
+
+		k.keys[id] = key
+		if k.current == "" {
+			k.current = id
+		}
+	}
+
+	if k.current == "" {
+		return nil, errors.New("models: no master keys given")
+	}
+
+	return k, nil
+}
+
+// The ID of the master key new data keys are wrapped with.
+func (k *Keyring) Current() string {
+	return k.current
+}
+
+// The content of a snippet or revision, as stored in the database. It's either
+// kept in the clear in Plaintext, or (when KeyID is set) encrypted in Ciphertext
+// with a data key, which is wrapped with the master key KeyID in WrappedKey.
+type sealedContent struct {
+	Plaintext  string
+	Ciphertext []byte
+	WrappedKey []byte
+	KeyID      sql.NullString
+}
+
+// The columns holding the content of a snippet or revision, in the order of the
+// fields of sealedContent.
+const contentColumns = `content, content_ciphertext, wrapped_key, key_id`
+
+// Encrypt content with a new data key, wrapped with the current master key.
+// A nil keyring means encryption at rest isn't enabled, and leaves the content in the clear.
+func (k *Keyring) seal(content string) (sealedContent, error) {
+	if k == nil {
+		return sealedContent{Plaintext: content}, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return sealedContent{}, err
+	}
+
+	ciphertext, err := encryptGCM(dataKey, []byte(content))
+	if err != nil {
+		return sealedContent{}, err
+	}
+
+	wrappedKey, err := encryptGCM(k.keys[k.current], dataKey)
+	if err != nil {
+		return sealedContent{}, err
+	}
+
+	// NOTE: the content column is left empty rather than NULL, so rows written
+	// before encryption at rest was enabled don't need a different schema.
+	return sealedContent{
+		Ciphertext: ciphertext,
+		WrappedKey: wrappedKey,
+		KeyID:      sql.NullString{String: k.current, Valid: true},
+	}, nil
+}
+
+// Decrypt stored content. Content stored in the clear is returned as it is, so
+// rows written before encryption at rest was enabled can still be read.
+func (k *Keyring) open(c sealedContent) (string, error) {
+	if !c.KeyID.Valid {
+		return c.Plaintext, nil
+	}
+
+	dataKey, err := k.unwrap(c)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := decryptGCM(dataKey, c.Ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// Wrap the data key of encrypted content with the current master key instead of
+// the one it was wrapped with, or encrypt content stored in the clear. The content
+// itself doesn't need to be encrypted again when the master key is rotated.
+func (k *Keyring) reseal(c sealedContent) (sealedContent, error) {
+	if !c.KeyID.Valid {
+		return k.seal(c.Plaintext)
+	}
+
+	dataKey, err := k.unwrap(c)
+	if err != nil {
+		return sealedContent{}, err
+	}
+
+	wrappedKey, err := encryptGCM(k.keys[k.current], dataKey)
+	if err != nil {
+		return sealedContent{}, err
+	}
+
+	c.WrappedKey = wrappedKey
+	c.KeyID = sql.NullString{String: k.current, Valid: true}
+	return c, nil
+}
+
+// Return the data key of encrypted content, unwrapped with its master key.
+func (k *Keyring) unwrap(c sealedContent) ([]byte, error) {
+	if k == nil {
+		return nil, ErrUndecryptable
+	}
+
+	masterKey, ok := k.keys[c.KeyID.String]
+	if !ok {
+		return nil, ErrUndecryptable
+	}
+
+	return decryptGCM(masterKey, c.WrappedKey)
+}
+
+// The tables holding content encrypted at rest.
+var sealedTables = []string{"snippets", "snippet_revisions", "snippet_files"}
+
+// How many rows Rekey() reads at a time.
+const rekeyBatchSize = 100
+
+// Bring all stored content up to date with the current master key, returning the
+// number of rows changed. Data keys wrapped with any other master key are re-wrapped
+// with the current one, and content still stored in the clear is encrypted.
+// Once it's done, the master keys other than the current one can be removed from the keyring.
+//
+// NOTE: it's safe to run while the web application is serving requests, as each
+// row is read and written back in a transaction holding a lock on it, so an edit
+// made in the meantime is never overwritten with the content read before it.
+// But every instance of the application must already have been restarted with
+// the new keyring: an instance still running with the old one keeps wrapping the
+// data keys of the snippets it writes with the old master key, which is then
+// removed from the keyring with those snippets still depending on it.
+func Rekey(db *sql.DB, keys *Keyring) (int, error) {
+	changed := 0
+
+	for _, table := range sealedTables {
+		n, err := rekeyTable(db, keys, table)
+		changed += n
+		if err != nil {
+			return changed, fmt.Errorf("models: rekeying %s: %w", table, err)
+		}
+	}
+
+	return changed, nil
+}
+
+// Rekey the rows of a single table, a batch at a time in order of ID.
+func rekeyTable(db *sql.DB, keys *Keyring, table string) (int, error) {
+	// NOTE: table is one of sealedTables, never user input, so it's safe to interpolate.
+	stmt := `SELECT id FROM ` + table + `
+  WHERE id > ? AND (key_id IS NULL OR key_id <> ?) ORDER BY id LIMIT ?`
+
+	changed, lastID := 0, 0
+	for {
+		var batch []int
+
+		tuples, err := db.Query(stmt, lastID, keys.Current(), rekeyBatchSize)
+		if err != nil {
+			return changed, err
+		}
+		for tuples.Next() {
+			var id int
+			if err := tuples.Scan(&id); err != nil {
+				tuples.Close()
+				return changed, err
+			}
+			batch = append(batch, id)
+		}
+		tuples.Close()
+		if err := tuples.Err(); err != nil {
+			return changed, err
+		}
+
+		if len(batch) == 0 {
+			return changed, nil
+		}
+
+		for _, id := range batch {
+			ok, err := rekeyRow(db, keys, table, id)
+			if err != nil {
+				return changed, fmt.Errorf("row %d: %w", id, err)
+			}
+			if ok {
+				changed++
+			}
+		}
+
+		lastID = batch[len(batch)-1]
+	}
+}
+
+// Rekey a single row, reporting whether it was changed. It's left alone if it
+// was deleted, or already written with the current master key, since it was listed.
+func rekeyRow(db *sql.DB, keys *Keyring, table string, id int) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
+	// NOTE: FOR UPDATE locks the row until the transaction ends, so the
+	// application can't change its content between reading and writing it here.
+	stmt := `SELECT ` + contentColumns + ` FROM ` + table + ` WHERE id = ? FOR UPDATE`
+
+	var c sealedContent
+	err = tx.QueryRow(stmt, id).Scan(&c.Plaintext, &c.Ciphertext, &c.WrappedKey, &c.KeyID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if c.KeyID.Valid && c.KeyID.String == keys.Current() {
+		return false, nil
+	}
+
+	resealed, err := keys.reseal(c)
+	if err != nil {
+		return false, err
+	}
+
+	stmt = `UPDATE ` + table + ` SET content = ?, content_ciphertext = ?, wrapped_key = ?, key_id = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, resealed.Plaintext, resealed.Ciphertext, resealed.WrappedKey, resealed.KeyID, id)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// Encrypt plaintext with AES-GCM, returning a random nonce followed by the ciphertext.
+func encryptGCM(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt the output of encryptGCM().
+func decryptGCM(key, ciphertext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, ErrUndecryptable
+	}
+
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrUndecryptable
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+// Two valid master keys, base64 encoded.
+const (
+	testKeyA = "AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8="
+	testKeyB = "ICEiIyQlJicoKSorLC0uLzAxMjM0NTY3ODk6Ozw9Pj8="
+)
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name        string
+		text        string
+		wantCurrent string
+		wantErr     bool
+	}{
+		{name: "Single", text: "one=" + testKeyA, wantCurrent: "one"},
+		{name: "Lines", text: "# rotated\ntwo=" + testKeyB + "\n\none=" + testKeyA + "\n", wantCurrent: "two"},
+		{name: "Commas", text: "two=" + testKeyB + ", one=" + testKeyA, wantCurrent: "two"},
+		{name: "Empty", text: "\n# nothing here\n", wantErr: true},
+		{name: "Missing ID", text: "=" + testKeyA, wantErr: true},
+		{name: "Missing key", text: "one", wantErr: true},
+		{name: "Short key", text: "one=AAECAwQFBgcICQoLDA0ODw==", wantErr: true},
+		{name: "Not base64", text: "one=not a key", wantErr: true},
+		{name: "Duplicate", text: "one=" + testKeyA + "\none=" + testKeyB, wantErr: true},
+		{name: "Long ID", text: strings.Repeat("x", 65) + "=" + testKeyA, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKeyring(tt.text)
+			if tt.wantErr {
+				assert.Equal(t, err != nil, true)
+				return
+			}
+
+			assert.NilError(t, err)
+			assert.Equal(t, k.Current(), tt.wantCurrent)
+		})
+	}
+}
+
+func TestKeyringSeal(t *testing.T) {
+	k, err := ParseKeyring("one=" + testKeyA)
+	assert.NilError(t, err)
+
+	content := "An old silent pond..."
+
+	sealed, err := k.seal(content)
+	assert.NilError(t, err)
+
+	assert.Equal(t, sealed.Plaintext, "")
+	assert.Equal(t, sealed.KeyID.String, "one")
+	assert.Equal(t, bytes.Contains(sealed.Ciphertext, []byte(content)), false)
+
+	opened, err := k.open(sealed)
+	assert.NilError(t, err)
+	assert.Equal(t, opened, content)
+
+	// Each seal uses a new data key.
+	other, err := k.seal(content)
+	assert.NilError(t, err)
+	assert.Equal(t, bytes.Equal(other.WrappedKey, sealed.WrappedKey), false)
+
+	// Tampering with the content is detected.
+	sealed.Ciphertext[len(sealed.Ciphertext)-1] ^= 1
+	_, err = k.open(sealed)
+	assert.Equal(t, errors.Is(err, ErrUndecryptable), true)
+}
+
+func TestKeyringPlaintext(t *testing.T) {
+	// Without a keyring, content is stored in the clear.
+	var none *Keyring
+
+	sealed, err := none.seal("Hello")
+	assert.NilError(t, err)
+	assert.Equal(t, sealed.Plaintext, "Hello")
+	assert.Equal(t, sealed.KeyID.Valid, false)
+
+	opened, err := none.open(sealed)
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "Hello")
+
+	// Content stored in the clear can still be read once a keyring is given.
+	k, err := ParseKeyring("one=" + testKeyA)
+	assert.NilError(t, err)
+
+	opened, err = k.open(sealed)
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "Hello")
+
+	// But encrypted content can't be read without one.
+	encrypted, err := k.seal("Hello")
+	assert.NilError(t, err)
+
+	_, err = none.open(encrypted)
+	assert.Equal(t, errors.Is(err, ErrUndecryptable), true)
+}
+
+func TestKeyringReseal(t *testing.T) {
+	old, err := ParseKeyring("one=" + testKeyA)
+	assert.NilError(t, err)
+
+	sealed, err := old.seal("Hello")
+	assert.NilError(t, err)
+
+	// Rotate the master key, keeping the old one.
+	rotated, err := ParseKeyring("two=" + testKeyB + "\none=" + testKeyA)
+	assert.NilError(t, err)
+
+	resealed, err := rotated.reseal(sealed)
+	assert.NilError(t, err)
+
+	// Only the data key is wrapped again, the content is left as it is.
+	assert.Equal(t, resealed.KeyID.String, "two")
+	assert.Equal(t, bytes.Equal(resealed.Ciphertext, sealed.Ciphertext), true)
+
+	// Once the old master key has been removed, the resealed content can still
+	// be read, but content wrapped with the old key can't.
+	current, err := ParseKeyring("two=" + testKeyB)
+	assert.NilError(t, err)
+
+	opened, err := current.open(resealed)
+	assert.NilError(t, err)
+	assert.Equal(t, opened, "Hello")
+
+	_, err = current.open(sealed)
+	assert.Equal(t, errors.Is(err, ErrUndecryptable), true)
+
+	// Content stored in the clear is encrypted.
+	resealed, err = current.reseal(sealedContent{Plaintext: "Hello"})
+	assert.NilError(t, err)
+	assert.Equal(t, resealed.Plaintext, "")
+	assert.Equal(t, resealed.KeyID.String, "two")
+}
@@ -0,0 +1,15 @@
+-- Upgrades a database from before snippets were linked to the users who create them.
+--
+-- NOTE: the migrations in this directory upgrade a database created with the
+-- original schema, of the snippets and users tables only, to the schema of
+-- internal/models/testdata/setup.sql. Run every one you haven't yet, in order,
+-- before deploying the version of the web application that needs it:
+--
+--   mysql -D snippetbox -u root -p < internal/models/migrations/001_user_ids.sql
+--
+-- A new database can instead be created from setup.sql, leaving out its test user.
+
+-- Existing snippets are left without an owner, so nobody can edit or delete them.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER;
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id);
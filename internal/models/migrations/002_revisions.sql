@@ -0,0 +1,18 @@
+-- Upgrades a database from before snippets kept their past revisions.
+
+CREATE TABLE snippet_revisions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
+  created DATETIME NOT NULL
+);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version);
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+-- Every snippet has at least its first version as a revision, which new
+-- snippets get when they're created, so existing ones are given theirs here.
+INSERT INTO snippet_revisions (snippet_id, version, title, content, created)
+  SELECT id, 1, title, content, created FROM snippets;
@@ -0,0 +1,5 @@
+-- Upgrades a database from before snippets were highlighted in a language.
+
+-- Existing snippets are left without one, and have it guessed from their content
+-- when they are shown.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(50) NOT NULL DEFAULT '' AFTER content;
@@ -0,0 +1,3 @@
+-- Upgrades a database from before snippets could be searched.
+
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
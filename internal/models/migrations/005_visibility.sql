@@ -0,0 +1,4 @@
+-- Upgrades a database from before snippets could be unlisted or private.
+
+-- Existing snippets stay public.
+ALTER TABLE snippets ADD COLUMN visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public' AFTER language;
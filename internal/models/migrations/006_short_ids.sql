@@ -0,0 +1,18 @@
+-- Upgrades a database from before snippets were addressed by short IDs. The
+-- web application using them can't find any snippet without one.
+--
+-- NOTE: the column is added as NULL first, and only made NOT NULL and UNIQUE
+-- once every existing snippet has been given a short ID, as adding it with the
+-- constraints in one go would fail on a table with more than one row.
+
+ALTER TABLE snippets
+  ADD COLUMN short_id CHAR(8) NULL AFTER id,
+  ADD COLUMN legacy BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Existing snippets are flagged as legacy, so their old integer URLs keep
+-- redirecting to them. Their short IDs are derived from their integer ID, so
+-- they're unique: an L followed by the ID in base 36, padded to 7 characters.
+UPDATE snippets SET short_id = CONCAT('L', LPAD(CONV(id, 10, 36), 7, '0')), legacy = TRUE;
+
+ALTER TABLE snippets MODIFY short_id CHAR(8) NOT NULL;
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_short_id UNIQUE (short_id);
@@ -0,0 +1,4 @@
+-- Upgrades a database from before snippets could be burnt after reading.
+
+-- NULL is no view limit, which existing snippets keep.
+ALTER TABLE snippets ADD COLUMN views_remaining INTEGER AFTER expires;
@@ -0,0 +1,4 @@
+-- Upgrades a database from before snippets could never expire.
+
+-- NULL is never expiring.
+ALTER TABLE snippets MODIFY expires DATETIME NULL;
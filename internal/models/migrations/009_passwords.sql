@@ -0,0 +1,3 @@
+-- Upgrades a database from before snippets could be protected by a password.
+
+ALTER TABLE snippets ADD COLUMN hashed_password CHAR(60) AFTER views_remaining;
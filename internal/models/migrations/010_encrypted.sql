@@ -0,0 +1,3 @@
+-- Upgrades a database from before snippets could be encrypted in the browser.
+
+ALTER TABLE snippets ADD COLUMN encrypted BOOLEAN NOT NULL DEFAULT FALSE AFTER hashed_password;
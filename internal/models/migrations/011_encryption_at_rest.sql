@@ -0,0 +1,14 @@
+-- Upgrades a database from before snippet content was encrypted at rest.
+--
+-- NOTE: existing content is left in the clear, with a NULL key_id, until it's
+-- encrypted by running the web application with -rekey.
+
+ALTER TABLE snippets
+  ADD COLUMN content_ciphertext MEDIUMBLOB AFTER content,
+  ADD COLUMN wrapped_key VARBINARY(60) AFTER content_ciphertext,
+  ADD COLUMN key_id VARCHAR(64) AFTER wrapped_key;
+
+ALTER TABLE snippet_revisions
+  ADD COLUMN content_ciphertext MEDIUMBLOB AFTER content,
+  ADD COLUMN wrapped_key VARBINARY(60) AFTER content_ciphertext,
+  ADD COLUMN key_id VARCHAR(64) AFTER wrapped_key;
@@ -0,0 +1,21 @@
+-- Upgrades a database from before snippets could have several files.
+
+-- The first file of a snippet is stored in the snippet itself. Existing
+-- snippets are left without a filename, and are downloaded as one made from
+-- their title.
+ALTER TABLE snippets ADD COLUMN filename VARCHAR(255) NOT NULL DEFAULT '' AFTER title;
+
+CREATE TABLE snippet_files (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  language VARCHAR(50) NOT NULL DEFAULT '',
+  content TEXT NOT NULL,
+  content_ciphertext MEDIUMBLOB,
+  wrapped_key VARBINARY(60),
+  key_id VARCHAR(64)
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position);
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+-- Upgrades a database from before snippets could be forked.
+
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER;
+
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_forked_from FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL;
@@ -0,0 +1,17 @@
+-- Upgrades a database from before snippets could be tagged.
+
+CREATE TABLE tags (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(32) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+  snippet_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (snippet_id, tag_id)
+);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_tag_id FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE;
@@ -0,0 +1,12 @@
+-- Upgrades a database from before snippets could be starred.
+
+CREATE TABLE stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_created ON stars(created);
+ALTER TABLE stars ADD CONSTRAINT stars_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE stars ADD CONSTRAINT stars_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
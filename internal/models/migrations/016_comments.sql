@@ -0,0 +1,16 @@
+-- Upgrades a database from before snippets could be commented on.
+
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  line INTEGER,
+  body TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME
+);
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_parent_id FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
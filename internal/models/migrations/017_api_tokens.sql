@@ -0,0 +1,15 @@
+-- Upgrades a database from before users could create API tokens.
+
+-- Only the SHA-256 hash of each API token is stored, in hex.
+CREATE TABLE api_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  scope ENUM('read', 'write') NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  last_used DATETIME
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash);
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.adpollak.net/internal/models"
+)
+
+// The comments on mockSnippet: a thread on its first line started by the
+// mocked alice@example.com with a reply from Bob, and a comment on the whole
+// snippet from Bob.
+var mockComments = []*models.Comment{
+	{
+		ID:             1,
+		SnippetID:      1,
+		SnippetShortID: "oldPond1",
+		UserID:         1,
+		Author:         "Alice",
+		Line:           1,
+		Body:           "Is it still silent after the splash?",
+		Created:        time.Now(),
+	},
+	{
+		ID:             2,
+		SnippetID:      1,
+		SnippetShortID: "oldPond1",
+		UserID:         2,
+		Author:         "Bob",
+		ParentID:       1,
+		Body:           "Silence again, says the last line.",
+		Created:        time.Now(),
+	},
+	{
+		ID:             3,
+		SnippetID:      1,
+		SnippetShortID: "oldPond1",
+		UserID:         2,
+		Author:         "Bob",
+		Body:           "A classic.",
+		Created:        time.Now(),
+		Updated:        time.Now(),
+	},
+}
+
+// Mocking the models.CommentModel.
+type CommentModel struct{}
+
+func (m *CommentModel) Insert(c *models.Comment) (int, error) {
+	return 4, nil
+}
+
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	for _, c := range mockComments {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
+
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	if snippetID == mockSnippet.ID {
+		return mockComments, nil
+	}
+	return []*models.Comment{}, nil
+}
+
+func (m *CommentModel) Update(id int, body string) error {
+	return nil
+}
+
+func (m *CommentModel) Delete(id int) error {
+	for _, c := range mockComments {
+		if c.ID == id {
+			return nil
+		}
+	}
+	return models.ErrNoRecord
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.adpollak.net/internal/models"
+)
+
+var mockRevisions = []*models.Revision{
+	{
+		ID:        2,
+		SnippetID: 1,
+		Version:   2,
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again.",
+		Created:   time.Now(),
+	},
+	{
+		ID:        1,
+		SnippetID: 1,
+		Version:   1,
+		Title:     "An old silent pond",
+		Content:   "An old silent pond...\nA frog jumps in,\nsplash! Silence again.",
+		Created:   time.Now(),
+	},
+}
+
+// Mocking the models.RevisionModel. Only snippet 1 has any revisions.
+type RevisionModel struct{}
+
+func (m *RevisionModel) All(snippetID int) ([]*models.Revision, error) {
+	if snippetID == 1 {
+		return mockRevisions, nil
+	}
+	return []*models.Revision{}, nil
+}
+
+func (m *RevisionModel) Get(snippetID, version int) (*models.Revision, error) {
+	for _, r := range mockRevisions {
+		if r.SnippetID == snippetID && r.Version == version {
+			return r, nil
+		}
+	}
+	return nil, models.ErrNoRecord
+}
@@ -1,32 +1,196 @@
 package mocks
 
 import (
+	"slices"
+	"strings"
 	"time"
 
 	"snippetbox.adpollak.net/internal/models"
 )
 
 var mockSnippet = &models.Snippet{
-	ID:      1,
-	Title:   "An old silent pond",
-	Content: "An old silent pond...",
-	Created: time.Now(),
-	Expires: time.Now(),
+	ID:         1,
+	ShortID:    "oldPond1",
+	Title:      "An old silent pond",
+	Content:    "An old silent pond...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	Author:     "Alice",
+	Forks:      1,
+	Tags:       []string{"haiku", "nature"},
+}
+
+// A fork of mockSnippet, owned by a different user than the mocked alice@example.com.
+var mockForkSnippet = &models.Snippet{
+	ID:                   9,
+	ShortID:              "forkPond",
+	Title:                "An old silent pond",
+	Content:              "An old silent pond, a frog jumps in...",
+	Language:             "plaintext",
+	Visibility:           models.VisibilityPublic,
+	Created:              time.Now(),
+	Expires:              time.Now(),
+	UserID:               2,
+	Author:               "Bob",
+	ForkedFrom:           1,
+	ForkedFromShortID:    "oldPond1",
+	ForkedFromVisibility: models.VisibilityPublic,
+}
+
+// A private snippet owned by the mocked alice@example.com.
+var mockPrivateSnippet = &models.Snippet{
+	ID:         4,
+	ShortID:    "smrRiver",
+	Title:      "A summer river",
+	Content:    "A summer river being crossed, how pleasing, with sandals in my hands!",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPrivate,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	Author:     "Alice",
+}
+
+// A snippet owned by a different user than the mocked alice@example.com, who
+// has starred it.
+var mockOtherSnippet = &models.Snippet{
+	ID:         3,
+	ShortID:    "wntrFrst",
+	Title:      "Over the wintry forest",
+	Content:    "Over the wintry forest, winds howl in rage...",
+	Language:   "plaintext",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     2,
+	Author:     "Bob",
+	Stars:      2,
+}
+
+// A burn-after-reading snippet owned by a different user than the mocked
+// alice@example.com.
+var mockBurnSnippet = &models.Snippet{
+	ID:             5,
+	ShortID:        "burnNote",
+	Title:          "The password",
+	Content:        "hunter2",
+	Language:       "plaintext",
+	Visibility:     models.VisibilityUnlisted,
+	Created:        time.Now(),
+	Expires:        time.Now(),
+	ViewsRemaining: 1,
+	UserID:         2,
+	Author:         "Bob",
+}
+
+// A password-protected snippet owned by a different user than the mocked
+// alice@example.com. Its password is "open sesame".
+var mockProtectedSnippet = &models.Snippet{
+	ID:         6,
+	ShortID:    "lockedUp",
+	Title:      "The treasure",
+	Content:    "Forty thieves",
+	Language:   "plaintext",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Protected:  true,
+	UserID:     2,
+	Author:     "Bob",
+}
+
+// An encrypted snippet owned by the mocked alice@example.com. Its content
+// stands in for an IV followed by AES-GCM ciphertext.
+var mockEncryptedSnippet = &models.Snippet{
+	ID:         7,
+	ShortID:    "encNote7",
+	Title:      "A secret",
+	Content:    "AAECAwQFBgcICQoLfd9nGGCgo4z2bHe4p1I0ynDv0kvDTXmS",
+	Visibility: models.VisibilityUnlisted,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	Encrypted:  true,
+	UserID:     1,
+	Author:     "Alice",
+}
+
+// A snippet of two files owned by the mocked alice@example.com.
+var mockFilesSnippet = &models.Snippet{
+	ID:         8,
+	ShortID:    "twoFiles",
+	Title:      "Hello, world",
+	Filename:   "main.go",
+	Content:    "package main\n\nfunc main() {}\n",
+	Language:   "Go",
+	Visibility: models.VisibilityPublic,
+	Created:    time.Now(),
+	Expires:    time.Now(),
+	UserID:     1,
+	Author:     "Alice",
+	Files: []*models.File{
+		{Filename: "go.mod", Language: "plaintext", Content: "module example.com/hello\n"},
+	},
 }
 
 // Simple struct that implements the same methods
 // as our production models.SnippetModel, but have
 // the methods return fixed dummy data.
-type SnippetModel struct{}
+type SnippetModel struct {
+	// Whether content is encrypted at rest, in which case Search() only
+	// matches titles, as with a keyring.
+	EncryptedAtRest bool
+}
 
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
-	return 2, nil
+func (m *SnippetModel) Insert(s *models.Snippet, expires time.Duration, password string) (string, error) {
+	return "newSnip2", nil
 }
 
-func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+// NOTE: Get returns a copy of the mocked snippet, as handlers such as
+// snippetEditPost modify the snippet they are given before updating it.
+func (m *SnippetModel) Get(shortID string) (*models.Snippet, error) {
+	switch shortID {
+	case "oldPond1":
+		s := *mockSnippet
+		return &s, nil
+	case "wntrFrst":
+		s := *mockOtherSnippet
+		return &s, nil
+	case "smrRiver":
+		s := *mockPrivateSnippet
+		return &s, nil
+	case "burnNote":
+		s := *mockBurnSnippet
+		return &s, nil
+	case "lockedUp":
+		s := *mockProtectedSnippet
+		return &s, nil
+	case "encNote7":
+		s := *mockEncryptedSnippet
+		return &s, nil
+	case "twoFiles":
+		s := *mockFilesSnippet
+		return &s, nil
+	case "forkPond":
+		s := *mockForkSnippet
+		return &s, nil
+	default:
+		return nil, models.ErrNoRecord
+	}
+}
+
+// The mocked snippets 1 and 4 predate short IDs, so can still be
+// found by their integer IDs.
+func (m *SnippetModel) GetLegacy(id int) (*models.Snippet, error) {
 	switch id {
 	case 1:
-		return mockSnippet, nil
+		s := *mockSnippet
+		return &s, nil
+	case 4:
+		s := *mockPrivateSnippet
+		return &s, nil
 	default:
 		return nil, models.ErrNoRecord
 	}
@@ -35,3 +199,85 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	return []*models.Snippet{mockSnippet}, nil
 }
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	switch userID {
+	case 1:
+		return []*models.Snippet{mockPrivateSnippet, mockSnippet}, nil
+	default:
+		return []*models.Snippet{}, nil
+	}
+}
+
+func (m *SnippetModel) Update(s *models.Snippet) error {
+	switch s.ID {
+	case 1, 3, 4, 7, 8:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Reveal(shortID string) (*models.Snippet, error) {
+	s, err := m.Get(shortID)
+	if err != nil {
+		return nil, err
+	}
+	if s.ViewsRemaining > 0 {
+		s.ViewsRemaining--
+	}
+	return s, nil
+}
+
+func (m *SnippetModel) Unlock(id int, password string) error {
+	if id != mockProtectedSnippet.ID {
+		return models.ErrNoRecord
+	}
+	if password != "open sesame" {
+		return models.ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	switch id {
+	case 1, 3, 4:
+		return nil
+	default:
+		return models.ErrNoRecord
+	}
+}
+
+func (m *SnippetModel) Search(query, tag string, limit, offset int) ([]*models.Snippet, error) {
+	if tag != "" && !slices.Contains(mockSnippet.Tags, tag) {
+		return []*models.Snippet{}, nil
+	}
+	searched := mockSnippet.Content
+	if m.EncryptedAtRest {
+		searched = mockSnippet.Title
+	}
+	if offset == 0 && strings.Contains(strings.ToLower(searched), strings.ToLower(query)) {
+		return []*models.Snippet{mockSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *SnippetModel) SearchesContent() bool {
+	return !m.EncryptedAtRest
+}
+
+func (m *SnippetModel) Page(c models.Cursor) (*models.Page, error) {
+	// There is only a single page, holding the mocked snippet.
+	if c.After > 0 || c.Before > 0 {
+		return &models.Page{Snippets: []*models.Snippet{}}, nil
+	}
+	return &models.Page{Snippets: []*models.Snippet{mockSnippet}}, nil
+}
+
+func (m *SnippetModel) ByTag(tag string, c models.Cursor) (*models.Page, error) {
+	// Only the mocked snippet is tagged, and there is only a single page.
+	if !slices.Contains(mockSnippet.Tags, tag) || c.After > 0 || c.Before > 0 {
+		return &models.Page{Snippets: []*models.Snippet{}}, nil
+	}
+	return &models.Page{Snippets: []*models.Snippet{mockSnippet}}, nil
+}
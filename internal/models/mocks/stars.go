@@ -0,0 +1,30 @@
+package mocks
+
+import "snippetbox.adpollak.net/internal/models"
+
+// Mocking the models.StarModel. The mocked alice@example.com has starred
+// mockOtherSnippet, and nobody else has starred anything.
+type StarModel struct{}
+
+func (m *StarModel) Star(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	return nil
+}
+
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	return userID == 1 && snippetID == mockOtherSnippet.ID, nil
+}
+
+func (m *StarModel) Favorites(userID int) ([]*models.Snippet, error) {
+	if userID == 1 {
+		return []*models.Snippet{mockOtherSnippet}, nil
+	}
+	return []*models.Snippet{}, nil
+}
+
+func (m *StarModel) MostStarred(limit int) ([]*models.Snippet, error) {
+	return []*models.Snippet{mockOtherSnippet}, nil
+}
@@ -0,0 +1,13 @@
+package mocks
+
+import "snippetbox.adpollak.net/internal/models"
+
+// Mocking the models.TagModel.
+type TagModel struct{}
+
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	return []*models.Tag{
+		{Name: "haiku", Count: 3},
+		{Name: "nature", Count: 1},
+	}, nil
+}
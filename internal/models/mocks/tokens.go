@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"time"
+
+	"snippetbox.adpollak.net/internal/models"
+)
+
+// The tokens of the mocked alice@example.com: a read-only one which was never
+// used, and one which can write.
+var mockTokens = []*models.Token{
+	{
+		ID:      2,
+		UserID:  1,
+		Name:    "CI",
+		Scope:   models.ScopeRead,
+		Created: time.Now(),
+	},
+	{
+		ID:       1,
+		UserID:   1,
+		Name:     "Laptop",
+		Scope:    models.ScopeWrite,
+		Created:  time.Now(),
+		LastUsed: time.Now(),
+	},
+}
+
+// Mocking the models.TokenModel. The tokens sbx_read and sbx_write are those
+// of mockTokens, and new tokens are always sbx_newToken.
+type TokenModel struct{}
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	return "sbx_newToken", nil
+}
+
+func (m *TokenModel) Authenticate(token string) (*models.Token, error) {
+	switch token {
+	case "sbx_read":
+		return mockTokens[0], nil
+	case "sbx_write":
+		return mockTokens[1], nil
+	default:
+		return nil, models.ErrInvalidCredentials
+	}
+}
+
+func (m *TokenModel) ByUser(userID int) ([]*models.Token, error) {
+	if userID == 1 {
+		return mockTokens, nil
+	}
+	return []*models.Token{}, nil
+}
+
+func (m *TokenModel) Delete(userID, id int) error {
+	if userID == 1 && (id == 1 || id == 2) {
+		return nil
+	}
+	return models.ErrNoRecord
+}
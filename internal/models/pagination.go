@@ -0,0 +1,89 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// A position in a listing ordered by descending ID, for keyset (cursor)
+// pagination. Rather than skipping a number of rows with OFFSET, each page
+// starts from the ID of the last row of the page before it, so pages stay
+// stable when rows are added and deep pages are as fast as the first.
+type Cursor struct {
+	After  int // list the rows with an ID lower than this, 0 to start from the newest
+	Before int // list the rows with an ID higher than this, when paging backwards
+	Limit  int // the number of rows on each page
+}
+
+// One page of a listing of snippets.
+type Page struct {
+	Snippets []*Snippet
+	Next     int // the After cursor of the next (older) page, 0 if this is the last page
+	Prev     int // the Before cursor of the previous (newer) page, 0 if this is the first page
+}
+
+// Run a paginated query over snippets. The stmt must select snippetColumns
+// from snippetTables, and end in a WHERE clause that the keyset condition can be
+// appended to, such as `... WHERE s.visibility = 'public'`.
+func paginate(db *sql.DB, keys *Keyring, stmt string, args []any, c Cursor) (*Page, error) {
+	// Paging backwards we have to read the rows in ascending order, so the
+	// rows nearest the cursor come first, and reverse them afterwards.
+	order := "DESC"
+	switch {
+	case c.Before > 0:
+		stmt += " AND s.id > ?"
+		args = append(args, c.Before)
+		order = "ASC"
+	case c.After > 0:
+		stmt += " AND s.id < ?"
+		args = append(args, c.After)
+	}
+
+	// Fetch one row more than we need to find out if there are more pages.
+	stmt += fmt.Sprintf(" ORDER BY s.id %s LIMIT ?", order)
+	args = append(args, c.Limit+1)
+
+	tuples, err := db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(tuples, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	more := len(snippets) > c.Limit
+	if more {
+		snippets = snippets[:c.Limit]
+	}
+
+	if order == "ASC" {
+		for i, j := 0, len(snippets)-1; i < j; i, j = i+1, j-1 {
+			snippets[i], snippets[j] = snippets[j], snippets[i]
+		}
+	}
+
+	page := &Page{Snippets: snippets}
+	if len(snippets) == 0 {
+		return page, nil
+	}
+
+	first, last := snippets[0].ID, snippets[len(snippets)-1].ID
+	if c.Before > 0 {
+		// We came back from an older page, so there is always a next one.
+		page.Next = last
+		if more {
+			page.Prev = first
+		}
+	} else {
+		if more {
+			page.Next = last
+		}
+		if c.After > 0 {
+			page.Prev = first
+		}
+	}
+
+	return page, nil
+}
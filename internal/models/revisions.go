@@ -0,0 +1,110 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type RevisionModelInterface interface {
+	All(snippetID int) ([]*Revision, error)
+	Get(snippetID, version int) (*Revision, error)
+}
+
+// A past (or the current) version of a snippet. Every time a snippet is
+// created or updated, a copy of its title and content is stored as a revision.
+type Revision struct {
+	ID        int
+	SnippetID int
+	Version   int // numbered from 1 for each snippet
+	Title     string
+	Content   string
+	Created   time.Time
+}
+
+// Wrap the database connection pool.
+type RevisionModel struct {
+	DB *sql.DB
+	// The master keys the content of revisions is encrypted at rest with, the
+	// same as the SnippetModel's.
+	Keys *Keyring
+}
+
+// Copy the current title and content of a snippet into a new revision,
+// numbered one after the latest existing revision of the snippet.
+// Runs inside the transaction that created or updated the snippet, so that
+// a change is never stored without its revision.
+// NOTE: content encrypted at rest is copied as it is, along with its wrapped
+// data key, so the revision shares the data key of the version of the snippet it copies.
+func insertRevision(tx *sql.Tx, snippetID int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, version, title, ` + contentColumns + `, created)
+  SELECT s.id,
+    (SELECT COALESCE(MAX(r.version), 0) + 1 FROM snippet_revisions r WHERE r.snippet_id = s.id),
+    s.title, s.content, s.content_ciphertext, s.wrapped_key, s.key_id, UTC_TIMESTAMP()
+  FROM snippets s WHERE s.id = ?`
+
+	_, err := tx.Exec(stmt, snippetID)
+	return err
+}
+
+// Return every revision of a snippet, most recent first.
+func (m *RevisionModel) All(snippetID int) ([]*Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, ` + contentColumns + `, created FROM snippet_revisions
+  WHERE snippet_id = ? ORDER BY version DESC`
+
+	tuples, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer tuples.Close()
+
+	revisions := []*Revision{}
+
+	for tuples.Next() {
+		r, err := scanRevision(tuples, m.Keys)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = tuples.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Return a single revision of a snippet by its version number.
+func (m *RevisionModel) Get(snippetID, version int) (*Revision, error) {
+	stmt := `SELECT id, snippet_id, version, title, ` + contentColumns + `, created FROM snippet_revisions
+  WHERE snippet_id = ? AND version = ?`
+
+	r, err := scanRevision(m.DB.QueryRow(stmt, snippetID, version), m.Keys)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// Copy a single tuple into a new Revision, decrypting its content with keys.
+func scanRevision(row scanner, keys *Keyring) (*Revision, error) {
+	r := &Revision{}
+	var content sealedContent
+
+	err := row.Scan(&r.ID, &r.SnippetID, &r.Version, &r.Title, &content.Plaintext, &content.Ciphertext, &content.WrappedKey, &content.KeyID, &r.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Content, err = keys.open(content)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
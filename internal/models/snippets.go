@@ -1,52 +1,284 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"errors"
+	"math/big"
+	"strings"
 	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Describe the methods the SnippetModel type should have.
 // (Mainly used for testing purposes)
 type SnippetModelInterface interface {
-	Insert(title string, content string, expires int) (int, error)
-	Get(id int) (*Snippet, error)
+	Insert(s *Snippet, expires time.Duration, password string) (string, error)
+	Get(shortID string) (*Snippet, error)
+	GetLegacy(id int) (*Snippet, error)
 	Latest() ([]*Snippet, error)
+	ByUser(userID int) ([]*Snippet, error)
+	Update(s *Snippet) error
+	Reveal(shortID string) (*Snippet, error)
+	Unlock(id int, password string) error
+	Delete(id int) error
+	Search(query, tag string, limit, offset int) ([]*Snippet, error)
+	SearchesContent() bool
+	Page(c Cursor) (*Page, error)
+	ByTag(tag string, c Cursor) (*Page, error)
 }
 
+// The visibility levels of a snippet.
+const (
+	VisibilityPublic   = "public"   // listed on the home page, archive and in search results
+	VisibilityUnlisted = "unlisted" // only opened by a direct link, and never listed
+	VisibilityPrivate  = "private"  // only visible to its owner
+)
+
 // Hold the data for an individual snippet.
 // Notice that the fields corresponds to fields in the MySQL
 // table.
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID         int
+	ShortID    string // random base62 ID the snippet is addressed by in URLs
+	Title      string
+	Filename   string // name of the first file of the snippet, may be empty if it's the only one
+	Content    string
+	Language   string // name of the language the content is highlighted as
+	Visibility string // one of VisibilityPublic, VisibilityUnlisted or VisibilityPrivate
+	Created    time.Time
+	Expires    time.Time // zero if the snippet never expires
+	// Number of views left before the snippet deletes itself, 0 if the
+	// number of views isn't limited.
+	ViewsRemaining int
+	Protected      bool // whether a password is needed to see the snippet
+	// Whether the content was encrypted in the browser, in which case it holds the
+	// ciphertext, and the key needed to decrypt it is never sent to the server.
+	Encrypted bool
+	UserID    int    // owner of the snippet, 0 if it has none
+	Author    string // name of the owner, joined from the users table
+	// The files of the snippet after its first, in order. Only read when a
+	// single snippet is fetched, not in listings.
+	Files []*File
+	// The ID of the snippet this one is a fork of, 0 if it isn't a fork or the
+	// original has since been deleted or has expired. Its short ID and visibility
+	// are joined from the original.
+	ForkedFrom           int
+	ForkedFromShortID    string
+	ForkedFromVisibility string
+	Forks                int      // the number of unexpired forks of the snippet
+	Tags                 []string // the names of the tags of the snippet, in alphabetical order
+	Stars                int      // the number of users who starred the snippet
+}
+
+// Return every file of the snippet, starting with its first file, made of the
+// snippet's own Filename, Language and Content.
+func (s *Snippet) AllFiles() []*File {
+	first := &File{Filename: s.Filename, Language: s.Language, Content: s.Content}
+	return append([]*File{first}, s.Files...)
+}
+
+// Whether the snippet may be seen by a user. Private snippets are only
+// visible to their owner; a userID of 0 means no user is logged in.
+func (s *Snippet) VisibleTo(userID int) bool {
+	if s.Visibility == VisibilityPrivate {
+		return s.OwnedBy(userID)
+	}
+	return true
+}
+
+// Whether the snippet is owned by a user; a userID of 0 means no user is logged in.
+func (s *Snippet) OwnedBy(userID int) bool {
+	return userID != 0 && s.UserID == userID
+}
+
+// The columns selected for a snippet from snippetTables, in the order scanSnippet() expects them.
+// The content is selected in the columns of contentColumns, as it may be encrypted.
+const snippetColumns = `s.id, s.short_id, s.title, s.content, s.content_ciphertext, s.wrapped_key, s.key_id,
+  s.filename, s.language, s.visibility, s.created, s.expires, COALESCE(s.views_remaining, 0), s.hashed_password IS NOT NULL, s.encrypted, COALESCE(s.user_id, 0), COALESCE(u.name, ''),
+  COALESCE(p.id, 0), COALESCE(p.short_id, ''), COALESCE(p.visibility, ''),
+  (SELECT COUNT(*) FROM snippets f WHERE f.forked_from = s.id AND (f.expires IS NULL OR f.expires > UTC_TIMESTAMP())),
+  (SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ' ') FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE st.snippet_id = s.id),
+  (SELECT COUNT(*) FROM stars sr WHERE sr.snippet_id = s.id)`
+
+// The tables snippetColumns are selected from. Snippets are always joined against
+// their owner so we can display the author, and against the snippet they were
+// forked from, if it hasn't expired, to link to it.
+const snippetTables = `snippets s
+  LEFT JOIN users u ON u.id = s.user_id
+  LEFT JOIN snippets p ON p.id = s.forked_from AND (p.expires IS NULL OR p.expires > UTC_TIMESTAMP())`
+
+// Either a *sql.Row or *sql.Rows, both of which we scan snippets from.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// Copy the snippetColumns of a single tuple into a new Snippet, decrypting its
+// content with keys.
+func scanSnippet(row scanner, keys *Keyring) (*Snippet, error) {
+	s := &Snippet{}
+	var (
+		content sealedContent
+		// expires is NULL for snippets which never expire, leaving s.Expires zero.
+		expires sql.NullTime
+		// The tags are separated by spaces, which tag names can't contain, and
+		// NULL for snippets without tags.
+		tags sql.NullString
+	)
+	err := row.Scan(&s.ID, &s.ShortID, &s.Title, &content.Plaintext, &content.Ciphertext, &content.WrappedKey, &content.KeyID,
+		&s.Filename, &s.Language, &s.Visibility, &s.Created, &expires, &s.ViewsRemaining, &s.Protected, &s.Encrypted, &s.UserID, &s.Author,
+		&s.ForkedFrom, &s.ForkedFromShortID, &s.ForkedFromVisibility, &s.Forks, &tags, &s.Stars)
+	if err != nil {
+		return nil, err
+	}
+	s.Expires = expires.Time
+	s.Tags = strings.Fields(tags.String)
+
+	s.Content, err = keys.open(content)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// The condition selecting only snippets which haven't expired yet.
+const unexpired = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP())`
+
+// The characters of a short ID, and its length. 62^8 IDs are plenty to make
+// guessing one impractical.
+const (
+	shortIDAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	shortIDLength   = 8
+)
+
+// How many times Insert() generates a new short ID when the one it picked
+// is already taken, before giving up.
+const shortIDAttempts = 5
+
+// Generate a random short ID using crypto/rand, so IDs can't be predicted from
+// one another.
+// NOTE: IDs consisting of digits only are never generated, so a short ID can
+// never be mistaken for one of the old integer IDs.
+func newShortID() (string, error) {
+	max := big.NewInt(int64(len(shortIDAlphabet)))
+	for {
+		id := make([]byte, shortIDLength)
+		for i := range id {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return "", err
+			}
+			id[i] = shortIDAlphabet[n.Int64()]
+		}
+
+		if !IsLegacyID(string(id)) {
+			return string(id), nil
+		}
+	}
+}
+
+// Report whether id is an old integer snippet ID, rather than a short ID.
+func IsLegacyID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
 }
 
 // Define a SnippetModel type which wraps a sql.DB connection pool.
 type SnippetModel struct {
 	DB *sql.DB
+	// The master keys the content of snippets is encrypted at rest with. If nil,
+	// content is stored in the clear.
+	Keys *Keyring
 }
 
-// Insert a new snippet into the database.
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// Insert a new snippet into the database, expiring after the given duration,
+// and return the short ID it was given. A duration of 0 means the snippet never expires.
+// If password isn't empty, it's needed to see the snippet.
+// The title, files, language, visibility, view limit, whether it's encrypted,
+// owner (UserID), the snippet it's a fork of (ForkedFrom) and tags are taken from s. The first revision of the snippet is stored alongside it.
+func (m *SnippetModel) Insert(s *Snippet, expires time.Duration, password string) (string, error) {
+	// Like the passwords of users, only a bcrypt hash of the password is stored.
+	// A snippet without a password has a NULL hashed_password.
+	var hashedPassword sql.NullString
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+		if err != nil {
+			return "", err
+		}
+		hashedPassword = sql.NullString{String: string(hash), Valid: true}
+	}
+
+	content, err := m.Keys.seal(s.Content)
+	if err != nil {
+		return "", err
+	}
+
+	// The snippet and its first revision are inserted in a single transaction,
+	// so we never end up with one without the other.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	// Rollback is a no-op once the transaction has been committed.
+	defer tx.Rollback()
+
 	// The SQL statement we want to execute.
 	// We use ? to indicate placeholder parameters for data we want to insert into the database.
 	// As the data is untrusted user input, we'd rather do this than interpolate data in the query.
 	// NOTE: `` is used since we split the string into multiple lines.
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-  VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (short_id, title, filename, ` + contentColumns + `, language, visibility, created, expires, views_remaining, hashed_password, encrypted, user_id, forked_from)
+  VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? SECOND), ?, ?, ?, ?, ?)`
 
-	// Use the Exec() method on the embedded connection pool to execute the statement.
-	// Takes in a SQL statement, followed by additional info for the query.
-	// Returns a sql.Result type, which contains basic information about what happened when the
-	// statement was executed.
-	// NOTE: it is common to ignore the sql.Result return value if not needed.
-	result, err := m.DB.Exec(stmt, title, content, expires)
-	if err != nil {
-		return 0, err
+	// NOTE: DATE_ADD() returns NULL when the interval is NULL, so a snippet
+	// which never expires gets a NULL expires.
+	expiresIn := sql.NullInt64{Int64: int64(expires / time.Second), Valid: expires > 0}
+
+	// A snippet without a view limit has a NULL views_remaining.
+	viewsRemaining := sql.NullInt64{Int64: int64(s.ViewsRemaining), Valid: s.ViewsRemaining > 0}
+
+	// And one that isn't a fork has a NULL forked_from.
+	forkedFrom := sql.NullInt64{Int64: int64(s.ForkedFrom), Valid: s.ForkedFrom > 0}
+
+	// Anonymous pastes have no owner, so a NULL user_id.
+	userID := sql.NullInt64{Int64: int64(s.UserID), Valid: s.UserID > 0}
+
+	var (
+		shortID string
+		result  sql.Result
+	)
+	for attempt := 1; ; attempt++ {
+		shortID, err = newShortID()
+		if err != nil {
+			return "", err
+		}
+
+		// Use the Exec() method on the transaction to execute the statement.
+		// Takes in a SQL statement, followed by additional info for the query.
+		// Returns a sql.Result type, which contains basic information about what happened when the
+		// statement was executed.
+		result, err = tx.Exec(stmt, shortID, s.Title, s.Filename, content.Plaintext, content.Ciphertext, content.WrappedKey, content.KeyID, s.Language, s.Visibility, expiresIn, viewsRemaining, hashedPassword, s.Encrypted, userID, forkedFrom)
+		if err == nil {
+			break
+		}
+
+		// If the short ID collides with an existing one, violating our snippets_uc_short_id
+		// key, try again with a new one. MySQL only rolls back the failed statement,
+		// so the transaction can carry on.
+		var mySQLError *mysql.MySQLError
+		if attempt < shortIDAttempts && errors.As(err, &mySQLError) &&
+			mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "snippets_uc_short_id") {
+			continue
+		}
+		return "", err
 	}
 
 	// Use the LastInsertId() method on the result to get the ID of our
@@ -54,30 +286,63 @@ func (m *SnippetModel) Insert(title string, content string, expires int) (int, e
 	// NOTE: not all drivers and dbs support this method; for example, postgres does NOT.
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	err = insertRevision(tx, int(id))
+	if err != nil {
+		return "", err
+	}
+
+	err = insertFiles(tx, m.Keys, int(id), s.Files)
+	if err != nil {
+		return "", err
+	}
+
+	err = setTags(tx, int(id), s.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
 	}
 
-	return int(id), nil
+	return shortID, nil
 }
 
-// Return a specific (single) snippet based on its id.
-func (m *SnippetModel) Get(id int) (*Snippet, error) {
+// Return a specific (single) snippet based on its short ID.
+func (m *SnippetModel) Get(shortID string) (*Snippet, error) {
 	// The SQL statement we want to execute.
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-  WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.short_id = ?`
+
+	return m.get(stmt, shortID)
+}
 
+// Return a snippet based on its old integer ID, so links from before short IDs
+// were introduced keep working. Only snippets which existed back then (those
+// flagged as legacy by migrations/006_short_ids.sql) can be found this way,
+// otherwise counting up the integer IDs would still list every snippet.
+func (m *SnippetModel) GetLegacy(id int) (*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.legacy AND s.id = ?`
+
+	return m.get(stmt, id)
+}
+
+// Return the single snippet selected by a query with one placeholder parameter.
+func (m *SnippetModel) get(stmt string, arg any) (*Snippet, error) {
 	// Use QueryRow() on the connection pool to execute our SQL statement, passing in the
-	// untrusted* id variable as the value for the placeholder parameter.
+	// untrusted* arg variable as the value for the placeholder parameter.
 	// This returns a pointer to a sql.Row object which holds the result from the database.
-	tuple := m.DB.QueryRow(stmt, id)
+	tuple := m.DB.QueryRow(stmt, arg)
 
-	// Initialize a pointer to a new zeroed Snippet
-	s := &Snippet{}
-
-	// Copy the values from each field in sql.Row to the corresponding field in the Snippet.
-	// Notice that arguments are pointers to the place you want to copy data to; we want to copy the
+	// Copy the values from each field in sql.Row to the corresponding field in a new Snippet.
+	// Notice that scanSnippet passes pointers to the place we want to copy data to; we want to copy the
 	// pointer to the location of the data, NOT copy the value.
-	err := tuple.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	s, err := scanSnippet(tuple, m.Keys)
 	if err != nil {
 		// Scenario: The query returns no tuples, in which case row.Scan()
 		// will return a sql.ErrNoRows error.
@@ -90,14 +355,162 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 		}
 	}
 
+	err = loadFiles(m.DB, m.Keys, s)
+	if err != nil {
+		return nil, err
+	}
+
 	// Everything OK, return Snippet
 	return s, nil
 }
 
-// Return the 10 most recently created snippets.
+// Update the title, first file (its filename, content and language), visibility
+// and tags of the existing snippet with the ID of s, storing the new version as a revision
+// so the previous ones aren't lost. The further files of the snippet are left as they are.
+// NOTE: checking the snippet is owned by the user making the change is left to the handler.
+func (m *SnippetModel) Update(s *Snippet) error {
+	content, err := m.Keys.seal(s.Content)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt := `UPDATE snippets SET title = ?, filename = ?, content = ?, content_ciphertext = ?, wrapped_key = ?, key_id = ?,
+  language = ?, visibility = ? WHERE id = ?`
+
+	_, err = tx.Exec(stmt, s.Title, s.Filename, content.Plaintext, content.Ciphertext, content.WrappedKey, content.KeyID, s.Language, s.Visibility, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(tx, s.ID)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, s.ID, s.Tags)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Return a snippet based on its short ID to be shown to a reader, counting the view
+// against its view limit. The snippet is deleted once its last view is used up, and
+// the returned snippet has the number of views remaining after this one.
+// Snippets without a view limit are returned as they are.
+func (m *SnippetModel) Reveal(shortID string) (*Snippet, error) {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// NOTE: FOR UPDATE locks the snippet until the transaction ends, so when two
+	// readers reveal a snippet at the same moment the second waits for the first
+	// to use up its view. If that was the last view, the second finds nothing.
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.short_id = ?
+  FOR UPDATE OF s`
+
+	s, err := scanSnippet(tx.QueryRow(stmt, shortID), m.Keys)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		}
+		return nil, err
+	}
+
+	// Read the files before the snippet (and so its files) might be deleted.
+	err = loadFiles(tx, m.Keys, s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.ViewsRemaining {
+	case 0:
+		// The number of views isn't limited.
+		return s, nil
+	case 1:
+		_, err = tx.Exec(`DELETE FROM snippets WHERE id = ?`, s.ID)
+	default:
+		_, err = tx.Exec(`UPDATE snippets SET views_remaining = views_remaining - 1 WHERE id = ?`, s.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	s.ViewsRemaining--
+	return s, nil
+}
+
+// Check the password of a password-protected snippet.
+// Returns ErrInvalidCredentials if the password is wrong, and ErrNoRecord if
+// no snippet with the given id has a password.
+func (m *SnippetModel) Unlock(id int, password string) error {
+	var hashedPassword []byte
+
+	stmt := `SELECT hashed_password FROM snippets WHERE id = ? AND hashed_password IS NOT NULL`
+
+	err := m.DB.QueryRow(stmt, id).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	// Compare the hashed password with the plain-text password the reader
+	// provided, the same way as when a user logs in.
+	err = bcrypt.CompareHashAndPassword(hashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete a snippet from the database.
+// Returns ErrNoRecord if no snippet with the given id exists.
+func (m *SnippetModel) Delete(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ?`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	// Unlike an UPDATE, a DELETE that matches a tuple always affects it,
+	// so we can use RowsAffected() to tell if the snippet existed.
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Return the 10 most recently created public snippets.
 func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-  WHERE expires > UTC_TIMESTAMP() ORDER BY id DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.visibility = 'public' ORDER BY s.id DESC LIMIT 10`
 
 	// Returns a resultset containg result of our query.
 	tuples, err := m.DB.Query(stmt)
@@ -105,6 +518,92 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		return nil, err
 	}
 
+	return scanSnippets(tuples, m.Keys)
+}
+
+// Return up to limit unexpired public snippets whose title or content match the
+// query, skipping the first offset matches. Uses the FULLTEXT index on
+// (title, content), and orders the snippets by relevance.
+// If tag isn't empty, only snippets with that tag are matched; with a tag but no
+// query, all of them are returned, most recent first.
+// Snippets with a view limit or a password are left out, as the results include
+// their content, and so are encrypted snippets, whose content can't be searched.
+// NOTE: the FULLTEXT index can't see content encrypted at rest either, so once
+// encryption at rest is enabled only titles are matched; see SearchesContent().
+func (m *SnippetModel) Search(query, tag string, limit, offset int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.visibility = 'public' AND s.views_remaining IS NULL AND s.hashed_password IS NULL
+  AND NOT s.encrypted`
+	var args []any
+
+	if tag != "" {
+		stmt += ` AND ` + taggedWith
+		args = append(args, tag)
+	}
+
+	if query != "" {
+		stmt += `
+  AND MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)
+  ORDER BY MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE) DESC, s.id DESC`
+		args = append(args, query, query)
+	} else {
+		stmt += ` ORDER BY s.id DESC`
+	}
+
+	stmt += ` LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	tuples, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(tuples, m.Keys)
+}
+
+// Report whether Search() matches the content of snippets as well as their
+// titles. It doesn't with encryption at rest, since encrypted rows leave the
+// content column the FULLTEXT index is built on empty. (Rows written before it
+// was enabled still match on their content, until Rekey() encrypts them.)
+func (m *SnippetModel) SearchesContent() bool {
+	return m.Keys == nil
+}
+
+// Return a page of every unexpired public snippet, most recent first.
+func (m *SnippetModel) Page(c Cursor) (*Page, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.visibility = 'public'`
+
+	return paginate(m.DB, m.Keys, stmt, nil, c)
+}
+
+// The condition selecting only snippets with the tag named by a placeholder parameter.
+const taggedWith = `s.id IN (SELECT st.snippet_id FROM snippet_tags st JOIN tags t ON t.id = st.tag_id WHERE t.name = ?)`
+
+// Return a page of the unexpired public snippets with a tag, most recent first.
+func (m *SnippetModel) ByTag(tag string, c Cursor) (*Page, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.visibility = 'public' AND ` + taggedWith
+
+	return paginate(m.DB, m.Keys, stmt, []any{tag}, c)
+}
+
+// Return all unexpired snippets owned by a user, most recent first, whatever
+// their visibility.
+func (m *SnippetModel) ByUser(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  WHERE ` + unexpired + ` AND s.user_id = ? ORDER BY s.id DESC`
+
+	tuples, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(tuples, m.Keys)
+}
+
+// Read every tuple of a resultset into a slice of snippets, decrypting their content with keys.
+func scanSnippets(tuples *sql.Rows, keys *Keyring) ([]*Snippet, error) {
 	// Ensure resultset is properly closed; this should come after the error check on Query
 	// otherwise, if it returns an error it will panic trying to close a nil resultset.
 	defer tuples.Close()
@@ -115,9 +614,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	// tuple to be acted on by the Scan() method. If iteration over all rows completes,
 	// the resultset automatically closes itself and frees-up the underlying database connection.
 	for tuples.Next() {
-		s := &Snippet{}
-
-		err := tuples.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		s, err := scanSnippet(tuples, keys)
 		if err != nil {
 			return nil, err
 		}
@@ -125,7 +622,7 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 	}
 
 	// Retrieve any potential error that occurred during iteration.
-	if err = tuples.Err(); err != nil {
+	if err := tuples.Err(); err != nil {
 		return nil, err
 	}
 
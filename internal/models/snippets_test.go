@@ -0,0 +1,241 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"snippetbox.adpollak.net/internal/assert"
+)
+
+func TestNewShortID(t *testing.T) {
+	seen := map[string]bool{}
+
+	for range 100 {
+		id, err := newShortID()
+		assert.NilError(t, err)
+
+		assert.Equal(t, len(id), shortIDLength)
+		assert.Equal(t, strings.Trim(id, shortIDAlphabet), "")
+		assert.Equal(t, IsLegacyID(id), false)
+		assert.Equal(t, seen[id], false)
+
+		seen[id] = true
+	}
+}
+
+func TestIsLegacyID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "Integer", id: "42", want: true},
+		{name: "Short ID", id: "aB3dE5gH", want: false},
+		{name: "Negative", id: "-1", want: false},
+		{name: "Decimal", id: "1.2", want: false},
+		{name: "Empty", id: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, IsLegacyID(tt.id), tt.want)
+		})
+	}
+}
+
+// An integration test of Search() with encryption at rest, where only the
+// titles of snippets are matched.
+func TestSnippetModelSearchEncrypted(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+
+	keys, err := ParseKeyring("a=" + testKeyA)
+	assert.NilError(t, err)
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db, Keys: keys}
+	assert.Equal(t, m.SearchesContent(), false)
+
+	_, err = m.Insert(&Snippet{
+		Title:      "Autumn moonlight",
+		Content:    "A worm digs silently into the chestnut",
+		Language:   "plaintext",
+		Visibility: VisibilityPublic,
+	}, 24*time.Hour, "")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+	}{
+		{name: "Title", query: "moonlight", wantCount: 1},
+		{name: "Content", query: "chestnut", wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippets, err := m.Search(tt.query, "", 10, 0)
+
+			assert.NilError(t, err)
+			assert.Equal(t, len(snippets), tt.wantCount)
+		})
+	}
+}
+
+// An integration test of Reveal() on a burn-after-reading snippet, which is
+// deleted once its last view is used up.
+func TestSnippetModelReveal(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	shortID, err := m.Insert(&Snippet{
+		Title:          "Old pond",
+		Content:        "A frog jumps into the water",
+		Language:       "plaintext",
+		Visibility:     VisibilityUnlisted,
+		ViewsRemaining: 2,
+	}, 24*time.Hour, "")
+	assert.NilError(t, err)
+
+	s, err := m.Reveal(shortID)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ViewsRemaining, 1)
+	assert.Equal(t, s.Content, "A frog jumps into the water")
+
+	s, err = m.Reveal(shortID)
+	assert.NilError(t, err)
+	assert.Equal(t, s.ViewsRemaining, 0)
+	assert.Equal(t, s.Content, "A frog jumps into the water")
+
+	_, err = m.Reveal(shortID)
+	assert.Equal(t, err, ErrNoRecord)
+
+	var count int
+	err = db.QueryRow("SELECT COUNT(*) FROM snippets WHERE short_id = ?", shortID).Scan(&count)
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+}
+
+// An integration test of the keyset pagination of Page(), forward across
+// the pages and back again.
+func TestSnippetModelPage(t *testing.T) {
+	if testing.Short() {
+		t.Skip("models: skipping integration tests")
+	}
+
+	db := newTestDB(t)
+	m := SnippetModel{DB: db}
+
+	// Five public snippets, ids[0] the oldest, and a private one which is never listed.
+	var ids []int
+	for i := range 6 {
+		visibility := VisibilityPublic
+		if i == 2 {
+			visibility = VisibilityPrivate
+		}
+
+		shortID, err := m.Insert(&Snippet{
+			Title:      "Old pond",
+			Content:    "A frog jumps into the water",
+			Language:   "plaintext",
+			Visibility: visibility,
+		}, 24*time.Hour, "")
+		assert.NilError(t, err)
+
+		if visibility == VisibilityPublic {
+			s, err := m.Get(shortID)
+			assert.NilError(t, err)
+			ids = append(ids, s.ID)
+		}
+	}
+
+	// Snippets created at the same time are still ordered by their ID, so none
+	// is skipped or repeated at the boundary of two pages.
+	_, err := db.Exec("UPDATE snippets SET created = '2024-01-01 10:00:00'")
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name     string
+		cursor   Cursor
+		wantIDs  []int
+		wantNext int
+		wantPrev int
+	}{
+		{
+			name:     "First page",
+			cursor:   Cursor{Limit: 2},
+			wantIDs:  []int{ids[4], ids[3]},
+			wantNext: ids[3],
+		},
+		{
+			name:     "Next page",
+			cursor:   Cursor{After: ids[3], Limit: 2},
+			wantIDs:  []int{ids[2], ids[1]},
+			wantNext: ids[1],
+			wantPrev: ids[2],
+		},
+		{
+			name:     "Last page",
+			cursor:   Cursor{After: ids[1], Limit: 2},
+			wantIDs:  []int{ids[0]},
+			wantPrev: ids[0],
+		},
+		{
+			// The extra row fetched finds there is no page after this one.
+			name:     "Last full page",
+			cursor:   Cursor{After: ids[2], Limit: 2},
+			wantIDs:  []int{ids[1], ids[0]},
+			wantPrev: ids[1],
+		},
+		{
+			name:     "Previous page",
+			cursor:   Cursor{Before: ids[0], Limit: 2},
+			wantIDs:  []int{ids[2], ids[1]},
+			wantNext: ids[1],
+			wantPrev: ids[2],
+		},
+		{
+			name:     "Previous first page",
+			cursor:   Cursor{Before: ids[2], Limit: 2},
+			wantIDs:  []int{ids[4], ids[3]},
+			wantNext: ids[3],
+		},
+		{
+			name:    "Past the end",
+			cursor:  Cursor{After: ids[0], Limit: 2},
+			wantIDs: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := m.Page(tt.cursor)
+			assert.NilError(t, err)
+
+			var gotIDs []int
+			for _, s := range page.Snippets {
+				gotIDs = append(gotIDs, s.ID)
+			}
+
+			assert.Equal(t, fmt.Sprint(gotIDs), fmt.Sprint(tt.wantIDs))
+			assert.Equal(t, page.Next, tt.wantNext)
+			assert.Equal(t, page.Prev, tt.wantPrev)
+		})
+	}
+}
+
+func TestSnippetModelSearchesContent(t *testing.T) {
+	keys, err := ParseKeyring("a=" + testKeyA)
+	assert.NilError(t, err)
+
+	assert.Equal(t, (&SnippetModel{}).SearchesContent(), true)
+	assert.Equal(t, (&SnippetModel{Keys: keys}).SearchesContent(), false)
+}
@@ -0,0 +1,81 @@
+package models
+
+import "database/sql"
+
+type StarModelInterface interface {
+	Star(userID, snippetID int) error
+	Unstar(userID, snippetID int) error
+	Starred(userID, snippetID int) (bool, error)
+	Favorites(userID int) ([]*Snippet, error)
+	MostStarred(limit int) ([]*Snippet, error)
+}
+
+// Wrap the database connection pool.
+type StarModel struct {
+	DB *sql.DB
+	// The master keys the content of snippets is encrypted at rest with, the
+	// same as the SnippetModel's.
+	Keys *Keyring
+}
+
+// Star a snippet for a user, adding it to their favorites. Starring a snippet
+// the user has already starred does nothing.
+func (m *StarModel) Star(userID, snippetID int) error {
+	stmt := `INSERT IGNORE INTO stars (user_id, snippet_id, created) VALUES (?, ?, UTC_TIMESTAMP())`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// Remove the star of a user from a snippet. Unstarring a snippet the user
+// hasn't starred does nothing.
+func (m *StarModel) Unstar(userID, snippetID int) error {
+	stmt := `DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// Whether a user has starred a snippet.
+func (m *StarModel) Starred(userID, snippetID int) (bool, error) {
+	var starred bool
+
+	stmt := `SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)`
+
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&starred)
+	return starred, err
+}
+
+// Return the unexpired snippets a user has starred, most recently starred first.
+// NOTE: snippets made private since they were starred are left out, unless the
+// user owns them.
+func (m *StarModel) Favorites(userID int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  JOIN stars st ON st.snippet_id = s.id
+  WHERE ` + unexpired + ` AND st.user_id = ? AND (s.visibility <> 'private' OR s.user_id = ?)
+  ORDER BY st.created DESC, s.id DESC`
+
+	tuples, err := m.DB.Query(stmt, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(tuples, m.Keys)
+}
+
+// Return up to limit unexpired public snippets starred most often in the last
+// 7 days, most starred first.
+func (m *StarModel) MostStarred(limit int) ([]*Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + ` FROM ` + snippetTables + `
+  JOIN (SELECT snippet_id, COUNT(*) AS weekly FROM stars
+    WHERE created > UTC_TIMESTAMP() - INTERVAL 7 DAY GROUP BY snippet_id) w ON w.snippet_id = s.id
+  WHERE ` + unexpired + ` AND s.visibility = 'public'
+  ORDER BY w.weekly DESC, s.id DESC LIMIT ?`
+
+	tuples, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(tuples, m.Keys)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql"
+	"sort"
+)
+
+type TagModelInterface interface {
+	Cloud(limit int) ([]*Tag, error)
+}
+
+// A tag grouping snippets by topic, such as "sql" or "regex", and the number of
+// unexpired public snippets tagged with it.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// The most tags a snippet can have, and the longest a tag can be, in characters.
+const (
+	MaxTags      = 10
+	MaxTagLength = 32
+)
+
+// Wrap the database connection pool.
+type TagModel struct {
+	DB *sql.DB
+}
+
+// Return up to limit of the tags used by the most unexpired public snippets,
+// in alphabetical order.
+func (m *TagModel) Cloud(limit int) ([]*Tag, error) {
+	stmt := `SELECT t.name, COUNT(*) FROM tags t
+  JOIN snippet_tags st ON st.tag_id = t.id
+  JOIN snippets s ON s.id = st.snippet_id
+  WHERE ` + unexpired + ` AND s.visibility = 'public'
+  GROUP BY t.id, t.name ORDER BY COUNT(*) DESC, t.name LIMIT ?`
+
+	tuples, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer tuples.Close()
+
+	tags := []*Tag{}
+
+	for tuples.Next() {
+		t := &Tag{}
+
+		err := tuples.Scan(&t.Name, &t.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err = tuples.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+// Replace the tags of a snippet, creating the tags which don't exist yet.
+// Runs inside the transaction that created or updated the snippet.
+// NOTE: tags no snippet uses any longer are left in the tags table; they
+// just aren't listed anywhere.
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range tags {
+		// LAST_INSERT_ID(id) makes LastInsertId() return the ID of the existing
+		// tag, when there already is one with the name.
+		result, err := tx.Exec(`INSERT INTO tags (name) VALUES (?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, name)
+		if err != nil {
+			return err
+		}
+
+		tagID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) VALUES (?, ?)`, snippetID, tagID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
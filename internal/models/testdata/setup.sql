@@ -1,12 +1,86 @@
 CREATE TABLE snippets (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  short_id CHAR(8) NOT NULL,
   title VARCHAR(100) NOT NULL,
+  filename VARCHAR(255) NOT NULL DEFAULT '',
   content TEXT NOT NULL,
+  content_ciphertext MEDIUMBLOB,
+  wrapped_key VARBINARY(60),
+  key_id VARCHAR(64),
+  language VARCHAR(50) NOT NULL DEFAULT '',
+  visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public',
   created DATETIME NOT NULL,
-  expires DATETIME NOT NULL
+  expires DATETIME,
+  views_remaining INTEGER,
+  hashed_password CHAR(60),
+  encrypted BOOLEAN NOT NULL DEFAULT FALSE,
+  user_id INTEGER,
+  legacy BOOLEAN NOT NULL DEFAULT FALSE,
+  forked_from INTEGER
 );
 
+ALTER TABLE snippets ADD CONSTRAINT snippets_uc_short_id UNIQUE (short_id);
+
+-- Snippets created before short IDs were introduced keep redirecting from their
+-- integer ID; existing databases are upgraded by migrations/006_short_ids.sql.
+
+-- With encryption at rest, content is left empty and content_ciphertext holds
+-- the content encrypted with a data key, which is stored in wrapped_key wrapped
+-- with the master key key_id. Rows where key_id is NULL hold their content in
+-- the clear, until they're encrypted by running the web application with -rekey.
+
 CREATE INDEX idx_snippets_created ON snippets(created);
+-- Forks outlive the snippet they were forked from, losing the link to it.
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_forked_from FOREIGN KEY (forked_from) REFERENCES snippets(id) ON DELETE SET NULL;
+CREATE FULLTEXT INDEX idx_snippets_fulltext ON snippets(title, content);
+
+CREATE TABLE snippet_revisions (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  version INTEGER NOT NULL,
+  title VARCHAR(100) NOT NULL,
+  content TEXT NOT NULL,
+  content_ciphertext MEDIUMBLOB,
+  wrapped_key VARBINARY(60),
+  key_id VARCHAR(64),
+  created DATETIME NOT NULL
+);
+
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_uc_version UNIQUE (snippet_id, version);
+ALTER TABLE snippet_revisions ADD CONSTRAINT snippet_revisions_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+-- The files of multi-file snippets after their first, which is stored in the
+-- snippet itself. The first further file is at position 1.
+CREATE TABLE snippet_files (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  position INTEGER NOT NULL,
+  filename VARCHAR(255) NOT NULL,
+  language VARCHAR(50) NOT NULL DEFAULT '',
+  content TEXT NOT NULL,
+  content_ciphertext MEDIUMBLOB,
+  wrapped_key VARBINARY(60),
+  key_id VARCHAR(64)
+);
+
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position);
+ALTER TABLE snippet_files ADD CONSTRAINT snippet_files_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+CREATE TABLE tags (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  name VARCHAR(32) NOT NULL
+);
+
+ALTER TABLE tags ADD CONSTRAINT tags_uc_name UNIQUE (name);
+
+CREATE TABLE snippet_tags (
+  snippet_id INTEGER NOT NULL,
+  tag_id INTEGER NOT NULL,
+  PRIMARY KEY (snippet_id, tag_id)
+);
+
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE snippet_tags ADD CONSTRAINT snippet_tags_fk_tag_id FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE;
 
 CREATE TABLE users (
   id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
@@ -18,6 +92,48 @@ CREATE TABLE users (
 
 ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
 
+ALTER TABLE snippets ADD CONSTRAINT snippets_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id);
+
+CREATE TABLE stars (
+  user_id INTEGER NOT NULL,
+  snippet_id INTEGER NOT NULL,
+  created DATETIME NOT NULL,
+  PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_created ON stars(created);
+ALTER TABLE stars ADD CONSTRAINT stars_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE stars ADD CONSTRAINT stars_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+
+-- Only the SHA-256 hash of each API token is stored, in hex.
+CREATE TABLE api_tokens (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  user_id INTEGER NOT NULL,
+  name VARCHAR(100) NOT NULL,
+  scope ENUM('read', 'write') NOT NULL,
+  token_hash CHAR(64) NOT NULL,
+  created DATETIME NOT NULL,
+  last_used DATETIME
+);
+
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_uc_token_hash UNIQUE (token_hash);
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE TABLE comments (
+  id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+  snippet_id INTEGER NOT NULL,
+  user_id INTEGER NOT NULL,
+  parent_id INTEGER,
+  line INTEGER,
+  body TEXT NOT NULL,
+  created DATETIME NOT NULL,
+  updated DATETIME
+);
+
+ALTER TABLE comments ADD CONSTRAINT comments_fk_snippet_id FOREIGN KEY (snippet_id) REFERENCES snippets(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_user_id FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE comments ADD CONSTRAINT comments_fk_parent_id FOREIGN KEY (parent_id) REFERENCES comments(id) ON DELETE CASCADE;
+
 INSERT INTO users (name, email, hashed_password, created) VALUES (
   'Alice Jones',
   'alice@example.com',
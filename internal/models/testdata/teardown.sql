@@ -1,3 +1,17 @@
-DROP TABLE users;
+DROP TABLE comments;
+
+DROP TABLE api_tokens;
+
+DROP TABLE stars;
+
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
+
+DROP TABLE snippet_files;
+
+DROP TABLE snippet_revisions;
 
 DROP TABLE snippets;
+
+DROP TABLE users;
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+type TokenModelInterface interface {
+	Insert(userID int, name, scope string) (string, error)
+	Authenticate(token string) (*Token, error)
+	ByUser(userID int) ([]*Token, error)
+	Delete(userID, id int) error
+}
+
+// A personal API token, which authenticates a user's CLI tools and CI jobs to
+// the JSON API in place of their password. Only a hash of the token is stored,
+// so the token itself is only ever shown once, when it's created.
+type Token struct {
+	ID       int
+	UserID   int
+	Name     string // what the user named the token, such as "laptop" or "CI"
+	Scope    string // one of ScopeRead or ScopeWrite
+	Created  time.Time
+	LastUsed time.Time // zero if the token was never used
+}
+
+// The scopes of a token: what it allows its bearer to do.
+const (
+	ScopeRead  = "read"  // read snippets, as the user
+	ScopeWrite = "write" // also create, change and delete the user's snippets
+)
+
+// The longest the name of a token can be, in characters.
+const MaxTokenNameLength = 100
+
+// Tokens are this prefix followed by 32 random bytes in base64url, which makes
+// them easy to recognize, such as by secret scanners.
+const tokenPrefix = "sbx_"
+
+// Wrap the database connection pool.
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Return a new random token.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Return the hex-encoded SHA-256 hash of a token, which is what's stored.
+// NOTE: unlike passwords, tokens are random enough that a fast hash is safe,
+// and a fast hash lets tokens be looked up by it on every request.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create a token for a user with the given name and scope, and return it.
+// This is the only time the token can be read, as only its hash is stored.
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, scope, token_hash, created)
+  VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, scope, hashToken(token))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Return the token matching the one given, recording that it was used.
+// Returns ErrInvalidCredentials if there is no such token, such as when it
+// was revoked.
+func (m *TokenModel) Authenticate(token string) (*Token, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrInvalidCredentials
+	}
+
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens WHERE token_hash = ?`
+
+	t := &Token{}
+	var lastUsed sql.NullTime
+
+	err := m.DB.QueryRow(stmt, hashToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	t.LastUsed = lastUsed.Time
+
+	_, err = m.DB.Exec(`UPDATE api_tokens SET last_used = UTC_TIMESTAMP() WHERE id = ?`, t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Return every token of a user, newest first.
+func (m *TokenModel) ByUser(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens
+  WHERE user_id = ? ORDER BY id DESC`
+
+	tuples, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer tuples.Close()
+
+	tokens := []*Token{}
+
+	for tuples.Next() {
+		t := &Token{}
+		var lastUsed sql.NullTime
+
+		err := tuples.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &lastUsed)
+		if err != nil {
+			return nil, err
+		}
+		t.LastUsed = lastUsed.Time
+		tokens = append(tokens, t)
+	}
+
+	if err = tuples.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke a token of a user, so it no longer authenticates anyone.
+// Returns ErrNoRecord if the user has no token with the given id.
+func (m *TokenModel) Delete(userID, id int) error {
+	stmt := `DELETE FROM api_tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
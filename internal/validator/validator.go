@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"cmp"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -73,12 +74,22 @@ func PermittedValue[T comparable](value T, permittedValues ...T) bool {
 	return false
 }
 
+// Check if a value is between min and max, inclusive. Works with any ordered
+// type, such as ints or time.Durations.
+func Between[T cmp.Ordered](value, min, max T) bool {
+	return value >= min && value <= max
+}
+
 // Use regexp.MustCompile() to parse a regex pattern for sanity checking the format of an email address.
 // Returns a pointer to a compiled regexp.Regexp type, or panics in the event of an error.
 // Parsing this pattern once at startup and storing the compiled *regexp.Regexp
 // in a variable is more performant than re-parsing the pattern each time we need it.
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// A regex pattern for tags: lowercase letters and digits, and after the first
+// character also '+', '.' and '-', such as "sql", "k8s" or "c++".
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]*$`)
+
 // Returns true if a value contains at least n characters.
 func MinChars(value string, n int) bool {
 	return utf8.RuneCountInString(value) >= n
@@ -0,0 +1,57 @@
+// Encryption of snippet content in the browser, with AES-GCM from the Web
+// Crypto API. The ciphertext is the unpadded base64url encoding of a random
+// 12 byte IV followed by the encrypted content; the key is a random 256 bit
+// AES key, also base64url encoded, which is only ever put in the URL fragment.
+
+const ivLength = 12;
+
+function toBase64URL(bytes) {
+	let binary = "";
+	for (const b of bytes) {
+		binary += String.fromCharCode(b);
+	}
+	return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function fromBase64URL(text) {
+	const binary = atob(text.replace(/-/g, "+").replace(/_/g, "/"));
+	const bytes = new Uint8Array(binary.length);
+	for (let i = 0; i < binary.length; i++) {
+		bytes[i] = binary.charCodeAt(i);
+	}
+	return bytes;
+}
+
+// Encrypt plaintext with a new random key, returning the ciphertext and the key.
+export async function encrypt(plaintext) {
+	const key = await crypto.subtle.generateKey({ name: "AES-GCM", length: 256 }, true, ["encrypt"]);
+	const iv = crypto.getRandomValues(new Uint8Array(ivLength));
+
+	const encrypted = await crypto.subtle.encrypt({ name: "AES-GCM", iv: iv }, key, new TextEncoder().encode(plaintext));
+
+	const payload = new Uint8Array(ivLength + encrypted.byteLength);
+	payload.set(iv);
+	payload.set(new Uint8Array(encrypted), ivLength);
+
+	const rawKey = await crypto.subtle.exportKey("raw", key);
+
+	return {
+		ciphertext: toBase64URL(payload),
+		key: toBase64URL(new Uint8Array(rawKey)),
+	};
+}
+
+// Decrypt ciphertext made by encrypt() with its key. Throws if the key is wrong
+// or the ciphertext has been tampered with.
+export async function decrypt(ciphertext, key) {
+	const payload = fromBase64URL(ciphertext);
+	const cryptoKey = await crypto.subtle.importKey("raw", fromBase64URL(key), { name: "AES-GCM" }, false, ["decrypt"]);
+
+	const decrypted = await crypto.subtle.decrypt(
+		{ name: "AES-GCM", iv: payload.subarray(0, ivLength) },
+		cryptoKey,
+		payload.subarray(ivLength),
+	);
+
+	return new TextDecoder().decode(decrypted);
+}
@@ -0,0 +1,76 @@
+// Creates and shows encrypted snippets. The server only ever sees the
+// ciphertext: the key is kept in the URL fragment, which browsers don't send.
+import { encrypt, decrypt } from "./crypto.js";
+
+// On the create page, encrypt the content and send it as JSON, then go to the
+// new snippet with the key added as the fragment.
+const form = document.querySelector("form.encrypted");
+
+function showErrors(messages) {
+	const box = form.querySelector(".error");
+	box.textContent = messages.join(" ");
+	box.classList.remove("hidden");
+}
+
+async function submitEncrypted(event) {
+	event.preventDefault();
+
+	const fields = new FormData(form);
+	const { ciphertext, key } = await encrypt(form.querySelector("#plaintext").value);
+
+	const response = await fetch(form.action, {
+		method: "POST",
+		credentials: "same-origin",
+		headers: {
+			"Content-Type": "application/json",
+			// nosurf accepts the CSRF token in this header, like the csrf_token field of a form.
+			"X-CSRF-Token": fields.get("csrf_token"),
+		},
+		body: JSON.stringify({
+			title: fields.get("title"),
+			content: ciphertext,
+			language: fields.get("language"),
+			visibility: fields.get("visibility"),
+			expires: fields.get("expires"),
+			expires_value: parseInt(fields.get("expires_value"), 10) || 0,
+			expires_unit: fields.get("expires_unit"),
+			max_views: parseInt(fields.get("max_views"), 10) || 0,
+		}),
+	});
+
+	if (response.status === 422) {
+		const body = await response.json();
+		showErrors(Object.keys(body.errors).map((field) => field + ": " + body.errors[field]));
+		return;
+	}
+	if (!response.ok) {
+		showErrors(["The snippet couldn't be created. Please try again."]);
+		return;
+	}
+
+	const body = await response.json();
+	window.location.assign(body.url + "#" + key);
+}
+
+if (form) {
+	form.addEventListener("submit", (event) => {
+		submitEncrypted(event).catch(() => showErrors(["The snippet couldn't be encrypted."]));
+	});
+}
+
+// On the view page, decrypt the content with the key in the fragment.
+const code = document.querySelector("code[data-ciphertext]");
+
+async function showDecrypted() {
+	const key = window.location.hash.slice(1);
+	try {
+		// textContent, so the plaintext is never parsed as HTML.
+		code.textContent = await decrypt(code.dataset.ciphertext, key);
+	} catch (err) {
+		document.querySelector(".decrypt-error").classList.remove("hidden");
+	}
+}
+
+if (code) {
+	showDecrypted();
+}
@@ -5,4 +5,116 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Highlight the lines of a snippet named by a fragment like #L12 or #L12-L20.
+// Ranges sent as a query parameter (?lines=12-20) are highlighted on the server,
+// so the highlighting is only changed when there is such a fragment.
+var codeLines = document.querySelectorAll("#code .line");
+
+function highlightFragment() {
+	var match = /^#L(\d+)(?:-L(\d+))?$/.exec(window.location.hash);
+	if (!match) {
+		return;
+	}
+	var start = parseInt(match[1], 10);
+	var end = match[2] ? parseInt(match[2], 10) : start;
+	if (start > end) {
+		var tmp = start;
+		start = end;
+		end = tmp;
+	}
+	for (var i = 0; i < codeLines.length; i++) {
+		var number = i + 1;
+		codeLines[i].classList.toggle("hl", number >= start && number <= end);
+	}
+}
+
+if (codeLines.length > 0) {
+	highlightFragment();
+	window.addEventListener("hashchange", highlightFragment);
+}
+
+// Shift-clicking a line number extends the highlighted range from the
+// first highlighted line, for links like #L12-L20.
+var lineNumbers = document.querySelectorAll("#code .ln");
+for (var i = 0; i < lineNumbers.length; i++) {
+	lineNumbers[i].addEventListener("click", function(event) {
+		var first = document.querySelector("#code .line.hl");
+		if (!event.shiftKey || !first) {
+			return;
+		}
+		event.preventDefault();
+		var from = first.id;
+		var to = this.getAttribute("href").substring(1);
+		window.location.hash = from == to ? from : from + "-" + to;
+	});
+}
+
+// Copy a permalink to a line to the clipboard. The link carries the line as
+// both a query parameter and a fragment, so it's highlighted with or without
+// JavaScript.
+var permalinks = document.querySelectorAll("#code .permalink");
+for (var i = 0; i < permalinks.length; i++) {
+	permalinks[i].addEventListener("click", function() {
+		var line = this.getAttribute("data-line");
+		var url = window.location.origin + window.location.pathname + "?lines=" + line + "#L" + line;
+		var button = this;
+		navigator.clipboard.writeText(url).then(function() {
+			button.classList.add("copied");
+			setTimeout(function() {
+				button.classList.remove("copied");
+			}, 1500);
+		});
+	});
+}
+
+// Forms marked with data-keep-fragment keep the fragment of the page's URL when
+// they're submitted, such as the key of an encrypted snippet when revealing or
+// unlocking it. Browsers keep a fragment across redirects, but not into the
+// action of a form.
+var fragmentForms = document.querySelectorAll("form[data-keep-fragment]");
+for (var i = 0; i < fragmentForms.length; i++) {
+	fragmentForms[i].addEventListener("submit", function() {
+		if (window.location.hash) {
+			this.action = this.getAttribute("action") + window.location.hash;
+		}
+	});
+}
+
+// The create form can have several files. "Add file" adds the fields of another
+// file, copied from the new-file template, and "Remove file" takes them away.
+// The fields of the files are named files[0].filename, files[0].content and so
+// on, in order, whenever a file is added or removed.
+var fileList = document.querySelector(".files");
+var addFile = document.querySelector(".add-file");
+var newFile = document.querySelector("#new-file");
+
+function numberFiles() {
+	var files = fileList.querySelectorAll(".file");
+	for (var i = 0; i < files.length; i++) {
+		var fields = files[i].querySelectorAll("[data-field]");
+		for (var j = 0; j < fields.length; j++) {
+			fields[j].name = "files[" + i + "]." + fields[j].getAttribute("data-field");
+		}
+	}
+}
+
+if (fileList && addFile && newFile) {
+	addFile.classList.remove("hidden");
+	numberFiles();
+
+	addFile.addEventListener("click", function() {
+		fileList.appendChild(newFile.content.cloneNode(true));
+		numberFiles();
+		fileList.lastElementChild.querySelector("[data-field=filename]").focus();
+	});
+
+	fileList.addEventListener("click", function(event) {
+		if (!event.target.classList.contains("remove-file")) {
+			return;
+		}
+		event.target.closest(".file").remove();
+		numberFiles();
+	});
+}